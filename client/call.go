@@ -0,0 +1,188 @@
+package client
+
+import (
+	gocontext "context"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/logger"
+	"math"
+	"time"
+)
+
+// 链接已断开，等待中及之后的调用均返回该错误
+var ErrClosed = errors.New("client: connection closed")
+
+/*
+	异步调用的结果
+	请求携带递增的序列号(seq)，服务器回复时原样带回，读goroutine据此将响应交给对应的 Future
+*/
+type Future struct {
+	c    *client
+	seq  uint32
+	done chan struct{}
+	msg  Message
+	err  error
+
+	// 接收响应的元数据，为空时不接收
+	trailer *map[string]string
+}
+
+// 响应到达或调用失败时关闭
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// 等待响应，ctx 结束时取消调用并返回 ctx.Err()，此后到达的响应被丢弃
+func (f *Future) Wait(ctx gocontext.Context) (Message, error) {
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		f.cancel(ctx.Err())
+		// 调用可能恰好已完成
+		<-f.done
+	}
+	return f.msg, f.err
+}
+
+// 取消调用，服务器收到取消消息后取消请求的 Go context；已完成的调用不受影响
+func (f *Future) Cancel() {
+	f.cancel(gocontext.Canceled)
+}
+
+func (f *Future) cancel(err error) {
+	if _, ok := f.c.takePending(f.seq); !ok {
+		return
+	}
+	f.c.enqueue(&context.Context{Type: context.Type_CANCEL, Seq: f.seq})
+	f.complete(nil, err)
+}
+
+// 等待响应并解析到 out，返回码不为 SUCCESS 时返回错误
+func (f *Future) Parse(ctx gocontext.Context, out proto.Message) error {
+	msg, err := f.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	return ParseResponse(msg, out)
+}
+
+func (f *Future) complete(msg Message, err error) {
+	f.msg, f.err = msg, err
+	if msg != nil && f.trailer != nil {
+		*f.trailer = msg.GetTrailer()
+	}
+	close(f.done)
+}
+
+// 发送请求并等待对应的响应，响应解析到 resp；ctx 的截止时间作为请求的超时时间发送给服务器
+func (c *client) Call(ctx gocontext.Context, serviceID, methodID uint32, req, resp proto.Message, opts ...CallOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		opts = append([]CallOption{Timeout(time.Until(deadline))}, opts...)
+	}
+	return c.Go(serviceID, methodID, req, opts...).Parse(ctx, resp)
+}
+
+// 异步发送请求，通过返回的 Future 获取响应
+func (c *client) Go(serviceID, methodID uint32, req proto.Message, opts ...CallOption) *Future {
+	options := newCallOptions(opts...)
+	f := &Future{c: c, done: make(chan struct{}), trailer: options.trailer}
+
+	data, err := proto.Marshal(req)
+	if err != nil {
+		f.complete(nil, err)
+		return f
+	}
+
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		f.complete(nil, ErrClosed)
+		return f
+	}
+	f.seq = c.nextSeq()
+	c.pending[f.seq] = f
+	c.lock.Unlock()
+
+	ctx := new(context.Context)
+	ctx.ServiceId = serviceID
+	ctx.MethodId = methodID
+	ctx.Data = data
+	ctx.Session = c.getSession()
+	ctx.Seq = f.seq
+	ctx.Metadata = c.outgoingMetadata(options.metadata)
+	ctx.Timeout = timeoutMillis(options.timeout)
+
+	// 链接恰好断开时，closePending 已经结束了该调用
+	c.enqueue(ctx)
+	return f
+}
+
+// 超时时间转换为毫秒，不足1毫秒的按1毫秒计算
+func timeoutMillis(d time.Duration) uint32 {
+	if d <= 0 {
+		return 0
+	}
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	if ms > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(ms)
+}
+
+// 生成非0的序列号，0 表示不关联响应。调用方需持有 c.lock
+func (c *client) nextSeq() uint32 {
+	for {
+		c.seq++
+		if _, ok := c.pending[c.seq]; c.seq != 0 && !ok {
+			return c.seq
+		}
+	}
+}
+
+// 取出序列号对应的调用
+func (c *client) takePending(seq uint32) (*Future, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	f, ok := c.pending[seq]
+	if ok {
+		delete(c.pending, seq)
+	}
+	return f, ok
+}
+
+// 将响应交给等待中的调用，返回 false 表示响应不属于任何调用
+func (c *client) resolve(ctx *context.Context) bool {
+	if ctx.GetSeq() == 0 {
+		return false
+	}
+
+	if f, ok := c.takePending(ctx.GetSeq()); ok {
+		f.complete(NewMessage(ctx), nil)
+	} else {
+		// 调用已超时或取消
+		log.Debugf("drop response serviceID = %d methodID = %d seq = %d", ctx.GetServiceId(), ctx.GetMethodId(), ctx.GetSeq())
+	}
+	return true
+}
+
+// 链接断开，所有等待中的调用返回 ErrClosed，打开的流被中止
+func (c *client) closePending() {
+	c.lock.Lock()
+	pending := c.pending
+	c.pending = make(map[uint32]*Future)
+	c.closed = true
+	c.lock.Unlock()
+
+	// 唤醒等待发送的调用方与写goroutine
+	close(c.done)
+
+	for _, f := range pending {
+		f.complete(nil, ErrClosed)
+	}
+	c.streams.AbortAll(ErrClosed)
+}
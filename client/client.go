@@ -1,17 +1,54 @@
 package client
 
-import "github.com/treeforest/gos/transport/context"
+import (
+	gocontext "context"
+	"crypto/tls"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/context"
+	"net"
+	"net/http"
+)
 
 type Client interface {
 	Dial(address string)
+	DialTLS(address string, config *tls.Config)
+	DialWebSocket(url string, header http.Header)
+	DialKCP(address string)
+	Connect(conn net.Conn)
 	Send(serviceID, methodID uint32, data []byte)
+
+	// 发送请求并等待对应的响应，响应解析到 resp；ctx 结束时返回 ctx.Err()，返回码不为 SUCCESS 时返回错误
+	Call(ctx gocontext.Context, serviceID, methodID uint32, req, resp proto.Message, opts ...CallOption) error
+
+	// 异步发送请求，通过返回的 Future 获取响应，可同时发出多个请求
+	Go(serviceID, methodID uint32, req proto.Message, opts ...CallOption) *Future
+
+	// 打开流，可实现服务端流、客户端流与双向流，多个流复用同一个链接；ctx 结束时流被中止
+	NewStream(ctx gocontext.Context, serviceID, methodID uint32, opts ...CallOption) (Stream, error)
+
+	// 设置默认的元数据，此后发送的请求均携带，value 为空时删除该 key。单次调用的元数据通过 Metadata 设置
+	SetMetadata(key, value string)
+
+	// 设置登录后获得的session，此后发送的请求均携带该session
+	SetSession(session string)
+
+	// 订阅服务器对 (serviceID, methodID) 的推送，handler 在读goroutine中调用，不应阻塞。
+	// 未订阅的推送与响应一样通过 Recv 获取
+	OnPush(serviceID, methodID uint32, handler PushHandler)
+
+	// 获取 Send 发送的请求的响应以及未订阅的推送，Call、Go 的响应不会出现在这里
 	Recv() Message
 }
 
+// 处理服务器推送的函数
+type PushHandler func(msg Message)
+
 type Message interface {
 	GetServiceID() uint32
 	GetMethodID() uint32
 	GetData() []byte
+
+	// 获取响应的元数据
+	GetTrailer() map[string]string
 	GetContext() *context.Context
-	SetData(data []byte)
 }
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/codec"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/utils/config/secrets"
+	"github.com/treeforest/gos/utils/config/secrets/box"
+	naclbox "golang.org/x/crypto/nacl/box"
+	"sync/atomic"
+)
+
+// box 加密后的数据至少包含 nonce(24)
+const boxNonceLength = 24
+
+// 发送握手消息，未设置压缩算法且未开启加密时不握手。
+// 开启加密时返回本次链接随机生成的 box 密钥，用于解密服务器回复的会话密钥
+func (c *client) sendHandshake() (secrets.Secrets, error) {
+	hs := new(context.Handshake)
+	if codec.SupportsCompression(c.packer) {
+		for _, compression := range c.compressions {
+			hs.Compressions = append(hs.Compressions, uint32(compression))
+		}
+	}
+
+	var boxSecrets secrets.Secrets
+	if c.encryption {
+		if !codec.SupportsEncryption(c.packer) {
+			return nil, codec.ErrEncryption
+		}
+		// 不校验服务器身份时会话密钥可被中间人替换，加密形同虚设
+		if len(c.serverPublicKey) == 0 {
+			return nil, errors.New("server public key is required for encryption")
+		}
+
+		publicKey, privateKey, err := naclbox.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		boxSecrets = box.NewSecrets(secrets.PublicKey(publicKey[:]), secrets.PrivateKey(privateKey[:]))
+		if err := boxSecrets.Init(); err != nil {
+			return nil, err
+		}
+		hs.PublicKey = publicKey[:]
+	}
+
+	if len(hs.Compressions) == 0 && !c.encryption {
+		return nil, nil
+	}
+
+	data, err := proto.Marshal(hs)
+	if err != nil {
+		return nil, err
+	}
+	data, err = proto.Marshal(&context.Context{Type: context.Type_HANDSHAKE, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	buf, err := c.packer.Pack(&codec.Frame{Data: data})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.conn.Write(buf)
+	return boxSecrets, err
+}
+
+// 处理服务器的握手回复，开启加密时返回绑定了会话密钥的封包、拆包模块
+func (c *client) onHandshake(data []byte, boxSecrets secrets.Secrets) (codec.Packer, error) {
+	hs := new(context.Handshake)
+	if err := proto.Unmarshal(data, hs); err != nil {
+		return nil, err
+	}
+
+	if compressions := hs.GetCompressions(); len(compressions) == 1 && codec.Compression(compressions[0]).Valid() {
+		atomic.StoreUint32(&c.compression, compressions[0])
+	}
+
+	if !c.encryption {
+		return nil, nil
+	}
+
+	if len(hs.GetPublicKey()) == 0 {
+		return nil, errors.New("server does not support encryption")
+	}
+	if !bytes.Equal(c.serverPublicKey, hs.GetPublicKey()) {
+		return nil, errors.New("server public key mismatch")
+	}
+	if len(hs.GetSessionKey()) < boxNonceLength {
+		return nil, errors.New("invalid session key")
+	}
+
+	key, err := boxSecrets.Decrypt(hs.GetSessionKey(), secrets.SenderPublicKey(hs.GetPublicKey()))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := codec.WithSessionKey(c.packer, key, codec.RoleClient)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	if c.sealed != nil {
+		c.lock.Unlock()
+		return nil, errors.New("duplicate handshake")
+	}
+	c.sealed = sealed
+	c.lock.Unlock()
+
+	// 恢复发送业务消息
+	close(c.handshaked)
+
+	return sealed, nil
+}
+
+// 使用协商的压缩算法及会话密钥封包
+func (c *client) pack(data []byte) ([]byte, error) {
+	f := &codec.Frame{Data: data}
+	compression := codec.Compression(atomic.LoadUint32(&c.compression))
+	if compression != codec.CompressNone && uint32(len(data)) >= c.compressThreshold {
+		f.Flags = uint8(compression)
+	}
+
+	packer := c.packer
+	c.lock.Lock()
+	if c.sealed != nil {
+		packer = c.sealed
+		f.Flags |= codec.FlagEncrypted
+	}
+	c.lock.Unlock()
+
+	return packer.Pack(f)
+}
@@ -1,18 +1,14 @@
 package client
 
 import (
+	"fmt"
 	"github.com/golang/protobuf/proto"
 	"github.com/treeforest/gos/transport/context"
-	"hash/crc32"
-	"sync"
+	"github.com/treeforest/gos/transport/status"
 )
 
 type message struct {
-	DataLen  uint32 // 消息长度
-	CheckSum uint32 // 检验和
-	Data     []byte // 消息数据
-	ctx      *context.Context
-	once 	 sync.Once
+	ctx *context.Context
 }
 
 func (m *message) GetServiceID() uint32 {
@@ -27,25 +23,16 @@ func (m *message) GetData() []byte {
 	return m.ctx.GetData()
 }
 
-func (m *message) GetContext() *context.Context {
-	m.once.Do(func() {
-		m.ctx = new(context.Context)
-		proto.Unmarshal(m.Data, m.ctx)
-	})
-	return m.ctx
+func (m *message) GetTrailer() map[string]string {
+	return m.ctx.GetTrailer()
 }
 
-func (m *message) SetData(data []byte) {
-	m.Data = data
+func (m *message) GetContext() *context.Context {
+	return m.ctx
 }
 
 func NewMessage(ctx *context.Context) *message {
-	data, _ := proto.Marshal(ctx)
-	return &message{
-		DataLen:  uint32(len(data)),
-		CheckSum: crc32.ChecksumIEEE(data),
-		Data:     data,
-	}
+	return &message{ctx: ctx}
 }
 
 func NewMessage2(serviceID, methodID uint32, msg []byte) *message {
@@ -53,10 +40,17 @@ func NewMessage2(serviceID, methodID uint32, msg []byte) *message {
 	ctx.ServiceId = serviceID
 	ctx.MethodId = methodID
 	ctx.Data = msg
-	data, _ := proto.Marshal(ctx)
-	return &message{
-		DataLen:  uint32(len(data)),
-		CheckSum: crc32.ChecksumIEEE(data),
-		Data:     data,
+	return NewMessage(ctx)
+}
+
+/*
+	解析服务器返回的响应数据
+	请求失败时返回携带错误信息的错误，可通过 status.FromError 获取错误码与错误详情
+*/
+func ParseResponse(msg Message, out proto.Message) error {
+	ctx := msg.GetContext()
+	if err := status.FromContext(ctx).Err(); err != nil {
+		return fmt.Errorf("serviceID=%d methodID=%d: %w", ctx.GetServiceId(), ctx.GetMethodId(), err)
 	}
+	return proto.Unmarshal(ctx.GetData(), out)
 }
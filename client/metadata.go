@@ -0,0 +1,85 @@
+package client
+
+import "time"
+
+// 调用选项
+type CallOption func(o *callOptions)
+
+type callOptions struct {
+	// 本次调用附加的元数据
+	metadata map[string]string
+
+	// 接收响应的元数据
+	trailer *map[string]string
+
+	// 请求的超时时间，0 表示不超时
+	timeout time.Duration
+}
+
+func newCallOptions(opts ...CallOption) callOptions {
+	var options callOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// 为本次调用附加元数据，覆盖默认元数据中相同的 key
+func Metadata(key, value string) CallOption {
+	return func(o *callOptions) {
+		if o.metadata == nil {
+			o.metadata = make(map[string]string)
+		}
+		o.metadata[key] = value
+	}
+}
+
+// 响应到达时将响应的元数据(trailer)保存到 md，错误响应同样携带
+func Trailer(md *map[string]string) CallOption {
+	return func(o *callOptions) {
+		o.trailer = md
+	}
+}
+
+/*
+	设置请求的超时时间，服务器超时后取消请求的 Go context，不再处理仍在排队的请求
+	Call 默认使用 ctx 的截止时间
+*/
+func Timeout(d time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = d
+	}
+}
+
+// 设置默认的元数据，此后发送的请求均携带；value 为空时删除该 key
+func (c *client) SetMetadata(key, value string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if value == "" {
+		delete(c.metadata, key)
+		return
+	}
+	if c.metadata == nil {
+		c.metadata = make(map[string]string)
+	}
+	c.metadata[key] = value
+}
+
+// 合并默认的元数据与本次调用附加的元数据，均为空时返回 nil
+func (c *client) outgoingMetadata(extra map[string]string) map[string]string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.metadata) == 0 && len(extra) == 0 {
+		return nil
+	}
+	md := make(map[string]string, len(c.metadata)+len(extra))
+	for k, v := range c.metadata {
+		md[k] = v
+	}
+	for k, v := range extra {
+		md[k] = v
+	}
+	return md
+}
@@ -2,25 +2,102 @@ package client
 
 import (
 	"container/list"
+	"crypto/tls"
 	"fmt"
+	"github.com/treeforest/gos/transport/codec"
 	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/kcp"
+	"github.com/treeforest/gos/transport/stream"
+	"github.com/treeforest/gos/transport/ws"
 	"github.com/treeforest/logger"
 	"github.com/golang/protobuf/proto"
-	"io"
 	"net"
-	"time"
+	"net/http"
+	"sync"
 )
 
+// 等待发送的消息数量上限，队列满时发送方阻塞
+const sendQueueLen = 1024
+
 type client struct {
-	conn      net.Conn
+	conn   net.Conn
+	packer codec.Packer
+
+	// 待读取的响应与推送，由 recvLock 保护
+	recvLock  sync.Mutex
 	recvQueue *list.List
-	sendQueue *list.List
+
+	// 等待写goroutine发送的消息
+	sendQueue chan *message
+
+	// 加密握手完成(或无需加密握手)时关闭，此后写goroutine才发送业务消息
+	handshaked chan struct{}
+
+	// 链接断开时关闭
+	done chan struct{}
+
+	// 客户端支持的压缩算法及压缩阈值
+	compressions      []codec.Compression
+	compressThreshold uint32
+
+	// 握手协商的压缩算法(codec.Compression)
+	compression uint32
+
+	// 登录后获得的session，由 lock 保护
+	session string
+
+	// 是否加密及服务器的 box 公钥
+	encryption      bool
+	serverPublicKey []byte
+
+	// 保护 sealed、pushHandlers、pending、seq、closed、metadata、streamID、session
+	lock sync.Mutex
+
+	// 默认的元数据
+	metadata map[string]string
+
+	// 等待响应的调用 map[seq]*Future
+	pending map[uint32]*Future
+
+	// 最近一次分配的请求序列号
+	seq uint32
+
+	// 链接已断开
+	closed bool
+
+	// 打开的流
+	streams *stream.Table
+
+	// 最近一次分配的流id
+	streamID uint32
+
+	// 流的接收窗口
+	streamWindow uint32
+
+	// 推送的处理函数 map[serviceID<<32|methodID]PushHandler
+	pushHandlers map[uint64]PushHandler
+
+	// 绑定了会话密钥的封包、拆包模块，加密握手完成后设置
+	sealed codec.Packer
 }
 
-func NewClient() Client {
+func NewClient(opts ...Option) Client {
+	options := newOptions(opts...)
 	return &client{
-		recvQueue: list.New(),
-		sendQueue: list.New(),
+		packer:            options.Packer,
+		recvQueue:         list.New(),
+		sendQueue:         make(chan *message, sendQueueLen),
+		handshaked:        make(chan struct{}),
+		done:              make(chan struct{}),
+		compressions:      options.Compressions,
+		compressThreshold: options.CompressThreshold,
+		pushHandlers:      make(map[uint64]PushHandler),
+		pending:           make(map[uint32]*Future),
+		metadata:          options.Metadata,
+		streams:           stream.NewTable(),
+		streamWindow:      options.StreamWindow,
+		encryption:        options.Encryption,
+		serverPublicKey:   options.ServerPublicKey,
 	}
 }
 
@@ -29,74 +106,218 @@ func (c *client) Send(serviceID, methodID uint32, data []byte) {
 	ctx.ServiceId = serviceID
 	ctx.MethodId = methodID
 	ctx.Data = data
+	ctx.Session = c.getSession()
+	ctx.Metadata = c.outgoingMetadata(nil)
 
-	c.sendQueue.PushBack(NewMessage(ctx))
+	c.enqueue(ctx)
 }
 
-func (c *client) Recv() Message {
-	if c.recvQueue.Len() == 0 {
+// 将消息交给写goroutine，链接断开后返回 ErrClosed
+func (c *client) enqueue(ctx *context.Context) error {
+	select {
+	case c.sendQueue <- NewMessage(ctx):
 		return nil
+	case <-c.done:
+		return ErrClosed
 	}
+}
+
+// 设置登录后获得的session
+func (c *client) SetSession(session string) {
+	c.lock.Lock()
+	c.session = session
+	c.lock.Unlock()
+}
+
+// 获取登录后获得的session
+func (c *client) getSession() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.session
+}
+
+// 订阅服务器的推送
+func (c *client) OnPush(serviceID, methodID uint32, handler PushHandler) {
+	c.lock.Lock()
+	c.pushHandlers[uint64(serviceID)<<32|uint64(methodID)] = handler
+	c.lock.Unlock()
+}
+
+// 获取推送的处理函数
+func (c *client) pushHandler(serviceID, methodID uint32) (PushHandler, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	handler, ok := c.pushHandlers[uint64(serviceID)<<32|uint64(methodID)]
+	return handler, ok
+}
+
+func (c *client) Recv() Message {
+	c.recvLock.Lock()
+	defer c.recvLock.Unlock()
 
 	e := c.recvQueue.Front()
-	msg := e.Value.(*message)
+	if e == nil {
+		return nil
+	}
 	c.recvQueue.Remove(e)
-	return msg
+	return e.Value.(*message)
 }
 
 func (c *client) Dial(address string) {
-	var err error
-	c.conn, err = net.Dial("tcp", "127.0.0.1:9999")
+	conn, err := net.Dial("tcp", address)
 	if err != nil {
 		panic(fmt.Errorf("dial error: %v", err))
 	}
 
+	c.start(conn)
+}
+
+// 使用TLS连接服务器，config 为空时使用默认配置
+func (c *client) DialTLS(address string, config *tls.Config) {
+	conn, err := tls.Dial("tcp", address, config)
+	if err != nil {
+		panic(fmt.Errorf("dial tls error: %v", err))
+	}
+
+	c.start(conn)
+}
+
+// 使用 WebSocket 连接服务器，url 形如 ws://127.0.0.1:9998/ws
+func (c *client) DialWebSocket(url string, header http.Header) {
+	conn, err := ws.Dial(url, header)
+	if err != nil {
+		panic(fmt.Errorf("dial websocket error: %v", err))
+	}
+
+	c.start(conn)
+}
+
+// 使用 KCP(可靠UDP) 连接服务器
+func (c *client) DialKCP(address string) {
+	conn, err := kcp.Dial(address, kcp.DefaultConfig())
+	if err != nil {
+		panic(fmt.Errorf("dial kcp error: %v", err))
+	}
+
+	c.start(conn)
+}
+
+// 使用已经建立好的链接
+func (c *client) Connect(conn net.Conn) {
+	c.start(conn)
+}
+
+// 开启读写goroutine
+func (c *client) start(conn net.Conn) {
+	c.conn = conn
+
+	// 与服务器协商压缩算法与会话密钥，握手消息先于其它消息发送
+	boxSecrets, err := c.sendHandshake()
+	if err != nil {
+		conn.Close()
+		panic(fmt.Errorf("handshake error: %v", err))
+	}
+	if !c.encryption {
+		close(c.handshaked)
+	}
+
 	// 开启读
 	go func() {
+		defer c.closePending()
+
+		pack := c.packer
+
 		for {
-			headData := make([]byte, 8)
-			_, err = io.ReadFull(c.conn, headData)
-			if err != nil {
-				log.Warn("read head error")
-				break
+			frame, err := pack.Unpack(c.conn)
+			if err == codec.ErrChecksum {
+				log.Warn("Checksum failed.")
+				continue
 			}
-
-			msg, err := UnpackHead(headData)
 			if err != nil {
-				log.Warn("transport unpack head error:", err)
+				log.Warn("transport unpack error:", err)
 				break
 			}
 
-			if msg.DataLen > 0 {
-				msg.Data = make([]byte, msg.DataLen)
-				_, err := io.ReadFull(c.conn, msg.Data)
-				if err != nil {
-					log.Warn("transport unpack data error:", err)
+			if len(frame.Data) > 0 {
+				// 加密链接上的明文数据包可能被伪造
+				if pack != c.packer && frame.Flags&codec.FlagEncrypted == 0 {
+					log.Warn("recv plaintext package on encrypted connection")
+					c.conn.Close()
 					break
 				}
 
 				ctx := new(context.Context)
-				proto.Unmarshal(msg.Data, ctx)
-				msg.ctx = ctx
+				if err := proto.Unmarshal(frame.Data, ctx); err != nil {
+					log.Warn("unmarshal context error:", err)
+					continue
+				}
 
-				c.recvQueue.PushBack(msg)
+				// 握手、心跳消息：收到 PING 时回复 PONG，不交给调用方；已订阅的推送交给处理函数，流消息交给对应的流
+				switch ctx.GetType() {
+				case context.Type_HANDSHAKE:
+					sealed, err := c.onHandshake(ctx.GetData(), boxSecrets)
+					if err != nil {
+						log.Error("handshake error:", err)
+						c.conn.Close()
+						return
+					}
+					if sealed != nil {
+						pack = sealed
+					}
+					continue
+				case context.Type_PING:
+					c.enqueue(&context.Context{Type: context.Type_PONG})
+					continue
+				case context.Type_PONG:
+					continue
+				case context.Type_REQUEST:
+					// 携带序列号的响应交给对应的调用
+					if c.resolve(ctx) {
+						continue
+					}
+				case context.Type_PUSH:
+					if handler, ok := c.pushHandler(ctx.GetServiceId(), ctx.GetMethodId()); ok {
+						handler(NewMessage(ctx))
+						continue
+					}
+				case context.Type_STREAM_DATA, context.Type_STREAM_END, context.Type_STREAM_RESET, context.Type_STREAM_WINDOW:
+					c.dispatchStream(ctx)
+					continue
+				}
+
+				c.recvLock.Lock()
+				c.recvQueue.PushBack(NewMessage(ctx))
+				c.recvLock.Unlock()
 			}
 		}
 	}()
 
-	// 开启写
+	// 开启写，加密握手完成前暂停发送业务消息
 	go func() {
+		// 写失败时关闭链接，读goroutine随之退出并结束等待中的调用
+		defer c.conn.Close()
+
+		select {
+		case <-c.handshaked:
+		case <-c.done:
+			return
+		}
+
 		for {
-			if c.sendQueue.Len() == 0 {
-				time.Sleep(time.Millisecond * 60)
-				continue
+			var msg *message
+			select {
+			case msg = <-c.sendQueue:
+			case <-c.done:
+				return
 			}
 
-			elem := c.sendQueue.Front()
-			msg := elem.Value.(*message)
-			c.sendQueue.Remove(elem)
+			data, err := proto.Marshal(msg.ctx)
+			if err != nil {
+				log.Warn("Marshal error")
+				break
+			}
 
-			buf, err := Pack(msg)
+			buf, err := c.pack(data)
 			if err != nil {
 				log.Warn("Pack error")
 				break
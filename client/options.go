@@ -0,0 +1,107 @@
+package client
+
+import (
+	"github.com/treeforest/gos/transport/codec"
+	"github.com/treeforest/gos/transport/stream"
+)
+
+// Client 的配置项
+type Options struct {
+	// 收到的数据包(含解压后)的最大大小，默认与服务器相同，防止服务器发送超大数据包耗尽内存
+	MaxPackageSize uint32
+
+	// 封包、拆包模块，需与服务器使用相同的包格式，为空时根据 MaxPackageSize 使用 Legacy 格式
+	Packer codec.Packer
+
+	// 客户端支持的压缩算法，按偏好排列，建立链接后与服务器握手协商，为空时不压缩。
+	// 仅 Versioned 包格式支持压缩；无论是否协商，收到的压缩数据都会被透明解压
+	Compressions []codec.Compression
+
+	// 数据大小达到该值时才压缩
+	CompressThreshold uint32
+
+	// 是否与服务器协商会话密钥并加密所有数据包，仅 Versioned 包格式支持加密
+	Encryption bool
+
+	// 服务器的 box 公钥，握手时校验服务器身份，开启加密时必须设置
+	ServerPublicKey []byte
+
+	// 默认的元数据，每个请求都会携带，如语言、客户端版本、设备id
+	Metadata map[string]string
+
+	// 每个流的接收窗口(字节)，服务器读取消息后归还窗口
+	StreamWindow uint32
+}
+
+// 设置 Options 的函数
+type Option func(o *Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		MaxPackageSize:    4096,
+		CompressThreshold: 256,
+		StreamWindow:      stream.DefaultWindow,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Packer == nil {
+		options.Packer = codec.NewLegacyPacker(options.MaxPackageSize)
+	}
+
+	return options
+}
+
+// 设置数据包的最大大小，为0时不限制。仅作用于默认的封包、拆包模块，
+// 通过 WithPacker 设置的模块使用创建时指定的大小
+func WithMaxPackageSize(size uint32) Option {
+	return func(o *Options) {
+		o.MaxPackageSize = size
+	}
+}
+
+// 设置封包、拆包模块
+func WithPacker(packer codec.Packer) Option {
+	return func(o *Options) {
+		o.Packer = packer
+	}
+}
+
+// 设置客户端支持的压缩算法及压缩阈值，compressions 按偏好排列
+func WithCompression(threshold uint32, compressions ...codec.Compression) Option {
+	return func(o *Options) {
+		o.CompressThreshold = threshold
+		o.Compressions = compressions
+	}
+}
+
+// 开启加密链接，serverPublicKey 为服务器的 box 公钥，用于校验服务器身份，不能为空：
+// 服务器公钥与握手回复不一致时断开链接，为空时 Dial 等方法 panic
+func WithEncryption(serverPublicKey []byte) Option {
+	return func(o *Options) {
+		o.Encryption = true
+		o.ServerPublicKey = serverPublicKey
+	}
+}
+
+// 设置默认的元数据，key 相同时后设置的覆盖先设置的
+func WithMetadata(md map[string]string) Option {
+	return func(o *Options) {
+		if o.Metadata == nil {
+			o.Metadata = make(map[string]string, len(md))
+		}
+		for k, v := range md {
+			o.Metadata[k] = v
+		}
+	}
+}
+
+// 设置每个流的接收窗口(字节)
+func WithStreamWindow(window uint32) Option {
+	return func(o *Options) {
+		if window > 0 {
+			o.StreamWindow = window
+		}
+	}
+}
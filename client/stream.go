@@ -0,0 +1,155 @@
+package client
+
+import (
+	gocontext "context"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/status"
+	"github.com/treeforest/gos/transport/stream"
+	"github.com/treeforest/logger"
+	"io"
+	"time"
+)
+
+/*
+	客户端的流
+	Send 与 Recv 可以在不同的goroutine中调用；ctx 结束时流被中止
+*/
+type Stream interface {
+	// 流的 Go context
+	Context() gocontext.Context
+
+	// 发送一条消息，服务端的接收窗口耗尽时阻塞。服务端已结束流时返回 io.EOF，通过 Recv 获取结果
+	Send(msg proto.Message) error
+
+	// 结束发送(半关闭)，此后依然可以接收
+	CloseSend() error
+
+	// 接收一条消息，服务端成功结束流时返回 io.EOF，失败时返回携带错误信息的错误
+	Recv(msg proto.Message) error
+
+	// 服务端结束流时回复的元数据，Recv 返回错误后可用
+	Trailer() map[string]string
+}
+
+type clientStream struct {
+	st *stream.Stream
+}
+
+func (s *clientStream) Context() gocontext.Context {
+	return s.st.Context()
+}
+
+func (s *clientStream) Send(msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return s.st.Send(data)
+}
+
+func (s *clientStream) CloseSend() error {
+	return s.st.CloseSend(nil)
+}
+
+func (s *clientStream) Recv(msg proto.Message) error {
+	data, err := s.st.Recv()
+	if err == io.EOF {
+		if err := status.FromContext(s.st.End()).Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+func (s *clientStream) Trailer() map[string]string {
+	return s.st.End().GetTrailer()
+}
+
+// 打开流，ctx 的截止时间作为流的超时时间发送给服务器
+func (c *client) NewStream(ctx gocontext.Context, serviceID, methodID uint32, opts ...CallOption) (Stream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		opts = append([]CallOption{Timeout(time.Until(deadline))}, opts...)
+	}
+	options := newCallOptions(opts...)
+
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return nil, ErrClosed
+	}
+	id := c.nextStreamID()
+	// 服务端告知接收窗口之前不能发送消息
+	st := stream.New(ctx, id, serviceID, methodID, c.streamWindow, 0, c.enqueue)
+	c.streams.Add(st)
+	c.lock.Unlock()
+
+	open := new(context.Context)
+	open.Type = context.Type_STREAM_OPEN
+	open.StreamId = id
+	open.ServiceId = serviceID
+	open.MethodId = methodID
+	open.Session = c.getSession()
+	open.Metadata = c.outgoingMetadata(options.metadata)
+	open.Timeout = timeoutMillis(options.timeout)
+	open.Window = c.streamWindow
+	if err := c.enqueue(open); err != nil {
+		c.streams.Remove(st)
+		st.Abort(err)
+		return nil, err
+	}
+
+	// ctx 结束时通知服务器中止流；流正常结束时 Release 同样会取消 Context
+	go func() {
+		<-st.Context().Done()
+		if c.streams.Remove(st) {
+			st.Reset(context.Code_ERR_CANCELED, ctx.Err())
+		}
+	}()
+
+	return &clientStream{st: st}, nil
+}
+
+// 生成未被使用的非0流id。调用方需持有 c.lock
+func (c *client) nextStreamID() uint32 {
+	for {
+		c.streamID++
+		if _, ok := c.streams.Get(c.streamID); c.streamID != 0 && !ok {
+			return c.streamID
+		}
+	}
+}
+
+// 将服务器发来的流消息交给对应的流，已结束的流的消息被忽略
+func (c *client) dispatchStream(ctx *context.Context) {
+	st, ok := c.streams.Get(ctx.GetStreamId())
+	if !ok {
+		return
+	}
+
+	switch ctx.GetType() {
+	case context.Type_STREAM_DATA:
+		if err := st.OnData(ctx.GetData()); err != nil {
+			log.Warnf("stream id = %d error: %v", st.ID(), err)
+			c.streams.Remove(st)
+			st.Reset(context.Code_ERR_FLOW_CONTROL, err)
+		}
+	case context.Type_STREAM_END:
+		// 服务端结束整个流
+		c.streams.Remove(st)
+		st.OnEnd(ctx, true)
+		st.Release()
+	case context.Type_STREAM_RESET:
+		c.streams.Remove(st)
+		st.Abort(stream.ResetError(ctx.GetResult()))
+	case context.Type_STREAM_WINDOW:
+		st.OnWindow(ctx.GetWindow())
+	}
+}
@@ -1,88 +1,79 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"github.com/golang/protobuf/proto"
 	"github.com/treeforest/gos/client"
 	"github.com/treeforest/gos/demo/pb"
-	"github.com/treeforest/logger"
-	"hash/crc32"
 	"io"
-	"net"
 	"time"
 )
 
-func write(conn net.Conn) {
+func call(dc demo.DemoClient) {
+	var cnt int32 = 0
 	for {
 		req := new(demo.HelloRequest)
 		req.Name = "tony"
-		msg, _ := proto.Marshal(req)
-		binaryMsg, _ := client.Pack(client.NewMessage2(uint32(demo.ServiceID_demo), uint32(demo.Event_Hello), msg))
 
-		_, err := conn.Write(binaryMsg)
-		if err != nil {
-			fmt.Printf("write error: %v\n", err)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		resp, err := dc.Hello(ctx, req)
+		cancel()
+		if err == client.ErrClosed {
+			fmt.Println("connection closed")
 			break
 		}
+		if err != nil {
+			fmt.Println("call hello error:", err)
+			continue
+		}
+		cnt++
+
+		fmt.Println(cnt, "--->Recv serviceID:", demo.Demo_ServiceID, " methodID:", demo.Demo_Hello_MethodID, ", resp:", resp)
 
 		time.Sleep(time.Second * 4)
 	}
 }
 
-func read(conn net.Conn) {
-	var cnt int32 = 0
-	for {
-		cnt++
-
-		headData := make([]byte, 8)
-		_, err := io.ReadFull(conn, headData)
-		if err != nil {
-			fmt.Println("read head error")
-			break
-		}
-
-		msg, err := client.UnpackHead(headData)
-		if err != nil {
-			fmt.Println("transport unpack head error:", err)
-			break
-		}
+// 通过双向流向服务器依次发送多个名字
+func chat(dc demo.DemoClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
 
-		if msg.DataLen > 0 {
-			// msg 有数据
-			// 根据dataLen将data读出来
-			data := make([]byte, msg.DataLen)
-			_, err := io.ReadFull(conn, data)
-			if err != nil {
-				fmt.Println("unpack data error:", err)
-				break
-			}
-			msg.SetData(data)
+	s, err := dc.Chat(ctx)
+	if err != nil {
+		fmt.Println("open chat error:", err)
+		return
+	}
 
-			// 校验和检测
-			if msg.CheckSum != crc32.ChecksumIEEE(msg.Data) {
-				log.Errorf("Checksum error.")
-				continue
+	go func() {
+		for _, name := range []string{"tony", "jack", "lucy"} {
+			if err := s.Send(&demo.HelloRequest{Name: name}); err != nil {
+				return
 			}
+		}
+		s.CloseSend()
+	}()
 
-			resp := new(demo.HelloResponse)
-			proto.Unmarshal(msg.GetContext().GetData(), resp)
-
-			// 读取数据完毕
-
-			fmt.Println(cnt, "--->Recv serviceID:", msg.GetServiceID(), " methodID:", msg.GetMethodID(), ", dataLen:", msg.DataLen, ", resp:", resp)
+	for {
+		resp := new(demo.HelloResponse)
+		if err := s.Recv(resp); err == io.EOF {
+			break
+		} else if err != nil {
+			fmt.Println("chat error:", err)
+			return
 		}
+		fmt.Println("--->Chat resp:", resp)
 	}
 }
 
 func main() {
 	fmt.Println("client start...")
 
-	conn, err := net.Dial("tcp", "127.0.0.1:9999")
-	if err != nil {
-		panic(fmt.Errorf("dial error: %v", err))
-	}
+	c := client.NewClient()
+	c.Dial("127.0.0.1:9999")
 
-	go read(conn)
+	dc := demo.NewDemoClient(c)
 
-	write(conn)
-}
\ No newline at end of file
+	chat(dc)
+	call(dc)
+}
@@ -1,13 +1,13 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.24.0-devel
+// 	protoc-gen-go v1.30.0
 // 	protoc        v3.12.3
 // source: demo.proto
 
 package demo
 
 import (
-	proto "github.com/golang/protobuf/proto"
+	_ "github.com/treeforest/gos/protoc-gen-gos/gos"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
@@ -21,96 +21,6 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
-// This is a compile-time assertion that a sufficiently up-to-date version
-// of the legacy proto package is being used.
-const _ = proto.ProtoPackageIsVersion4
-
-type ServiceID int32
-
-const (
-	ServiceID_demo ServiceID = 0
-)
-
-// Enum value maps for ServiceID.
-var (
-	ServiceID_name = map[int32]string{
-		0: "demo",
-	}
-	ServiceID_value = map[string]int32{
-		"demo": 0,
-	}
-)
-
-func (x ServiceID) Enum() *ServiceID {
-	p := new(ServiceID)
-	*p = x
-	return p
-}
-
-func (x ServiceID) String() string {
-	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
-}
-
-func (ServiceID) Descriptor() protoreflect.EnumDescriptor {
-	return file_demo_proto_enumTypes[0].Descriptor()
-}
-
-func (ServiceID) Type() protoreflect.EnumType {
-	return &file_demo_proto_enumTypes[0]
-}
-
-func (x ServiceID) Number() protoreflect.EnumNumber {
-	return protoreflect.EnumNumber(x)
-}
-
-// Deprecated: Use ServiceID.Descriptor instead.
-func (ServiceID) EnumDescriptor() ([]byte, []int) {
-	return file_demo_proto_rawDescGZIP(), []int{0}
-}
-
-type Event int32
-
-const (
-	Event_Hello Event = 0
-)
-
-// Enum value maps for Event.
-var (
-	Event_name = map[int32]string{
-		0: "Hello",
-	}
-	Event_value = map[string]int32{
-		"Hello": 0,
-	}
-)
-
-func (x Event) Enum() *Event {
-	p := new(Event)
-	*p = x
-	return p
-}
-
-func (x Event) String() string {
-	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
-}
-
-func (Event) Descriptor() protoreflect.EnumDescriptor {
-	return file_demo_proto_enumTypes[1].Descriptor()
-}
-
-func (Event) Type() protoreflect.EnumType {
-	return &file_demo_proto_enumTypes[1]
-}
-
-func (x Event) Number() protoreflect.EnumNumber {
-	return protoreflect.EnumNumber(x)
-}
-
-// Deprecated: Use Event.Descriptor instead.
-func (Event) EnumDescriptor() ([]byte, []int) {
-	return file_demo_proto_rawDescGZIP(), []int{1}
-}
-
 type HelloRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -208,15 +118,22 @@ func (x *HelloResponse) GetRet() string {
 var File_demo_proto protoreflect.FileDescriptor
 
 var file_demo_proto_rawDesc = []byte{
-	0x0a, 0x0a, 0x64, 0x65, 0x6d, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x22, 0x0a, 0x0c,
-	0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04,
-	0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65,
-	0x22, 0x21, 0x0a, 0x0d, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
-	0x65, 0x12, 0x10, 0x0a, 0x03, 0x72, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
-	0x72, 0x65, 0x74, 0x2a, 0x15, 0x0a, 0x09, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x49, 0x44,
-	0x12, 0x08, 0x0a, 0x04, 0x64, 0x65, 0x6d, 0x6f, 0x10, 0x00, 0x2a, 0x12, 0x0a, 0x05, 0x45, 0x76,
-	0x65, 0x6e, 0x74, 0x12, 0x09, 0x0a, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x10, 0x00, 0x42, 0x08,
-	0x5a, 0x06, 0x2e, 0x3b, 0x64, 0x65, 0x6d, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x0a, 0x0a, 0x64, 0x65, 0x6d, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x09, 0x67, 0x6f,
+	0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x22, 0x0a, 0x0c, 0x48, 0x65, 0x6c, 0x6c, 0x6f,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x21, 0x0a, 0x0d, 0x48,
+	0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x10, 0x0a, 0x03,
+	0x72, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x72, 0x65, 0x74, 0x32, 0x6b,
+	0x0a, 0x04, 0x44, 0x65, 0x6d, 0x6f, 0x12, 0x2c, 0x0a, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x12,
+	0x0d, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0e,
+	0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x04,
+	0xd0, 0xf3, 0x18, 0x01, 0x12, 0x2f, 0x0a, 0x04, 0x43, 0x68, 0x61, 0x74, 0x12, 0x0d, 0x2e, 0x48,
+	0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e, 0x48, 0x65,
+	0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x04, 0xd0, 0xf3, 0x18,
+	0x02, 0x28, 0x01, 0x30, 0x01, 0x1a, 0x04, 0xc8, 0xf3, 0x18, 0x01, 0x42, 0x28, 0x5a, 0x26, 0x67,
+	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x74, 0x72, 0x65, 0x65, 0x66, 0x6f,
+	0x72, 0x65, 0x73, 0x74, 0x2f, 0x67, 0x6f, 0x73, 0x2f, 0x64, 0x65, 0x6d, 0x6f, 0x2f, 0x70, 0x62,
+	0x3b, 0x64, 0x65, 0x6d, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -231,17 +148,18 @@ func file_demo_proto_rawDescGZIP() []byte {
 	return file_demo_proto_rawDescData
 }
 
-var file_demo_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
 var file_demo_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
 var file_demo_proto_goTypes = []interface{}{
-	(ServiceID)(0),        // 0: ServiceID
-	(Event)(0),            // 1: Event
-	(*HelloRequest)(nil),  // 2: HelloRequest
-	(*HelloResponse)(nil), // 3: HelloResponse
+	(*HelloRequest)(nil),  // 0: HelloRequest
+	(*HelloResponse)(nil), // 1: HelloResponse
 }
 var file_demo_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
+	0, // 0: Demo.Hello:input_type -> HelloRequest
+	0, // 1: Demo.Chat:input_type -> HelloRequest
+	1, // 2: Demo.Hello:output_type -> HelloResponse
+	1, // 3: Demo.Chat:output_type -> HelloResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -283,14 +201,13 @@ func file_demo_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_demo_proto_rawDesc,
-			NumEnums:      2,
+			NumEnums:      0,
 			NumMessages:   2,
 			NumExtensions: 0,
-			NumServices:   0,
+			NumServices:   1,
 		},
 		GoTypes:           file_demo_proto_goTypes,
 		DependencyIndexes: file_demo_proto_depIdxs,
-		EnumInfos:         file_demo_proto_enumTypes,
 		MessageInfos:      file_demo_proto_msgTypes,
 	}.Build()
 	File_demo_proto = out.File
@@ -0,0 +1,79 @@
+// Code generated by protoc-gen-gos. DO NOT EDIT.
+// source: demo.proto
+
+package demo
+
+import (
+	context "context"
+	client "github.com/treeforest/gos/client"
+	transport "github.com/treeforest/gos/transport"
+)
+
+// Demo 服务的服务id与方法id
+const (
+	Demo_ServiceID      uint32 = 1
+	Demo_Hello_MethodID uint32 = 1
+	Demo_Chat_MethodID  uint32 = 2
+)
+
+// DemoServer 为 Demo 服务的服务端接口
+type DemoServer interface {
+	// 打招呼
+	Hello(req transport.Request, in *HelloRequest) (*HelloResponse, error)
+	// 双向流：对每个收到的名字打招呼
+	Chat(s transport.ServerStream) error
+}
+
+// 将 Demo 服务的处理函数注册到 Server
+func RegisterDemoServer(s transport.Server, srv DemoServer) {
+	s.RegisterHandler(Demo_ServiceID, Demo_Hello_MethodID, srv.Hello)
+	s.RegisterStreamHandler(Demo_ServiceID, Demo_Chat_MethodID, srv.Chat)
+}
+
+// DemoClient 为 Demo 服务的客户端代理
+type DemoClient interface {
+	// 打招呼
+	Hello(ctx context.Context, in *HelloRequest, opts ...client.CallOption) (*HelloResponse, error)
+
+	// 异步调用 Hello，通过 ParseHelloResponse 解析 Future 的结果
+	HelloAsync(in *HelloRequest, opts ...client.CallOption) *client.Future
+
+	// 解析 Hello 的响应
+	ParseHelloResponse(msg client.Message) (*HelloResponse, error)
+
+	// 双向流：对每个收到的名字打招呼
+	// 流式调用，发送 HelloRequest，接收 HelloResponse
+	Chat(ctx context.Context, opts ...client.CallOption) (client.Stream, error)
+}
+
+type demoClient struct {
+	c client.Client
+}
+
+func NewDemoClient(c client.Client) DemoClient {
+	return &demoClient{c: c}
+}
+
+func (c *demoClient) Hello(ctx context.Context, in *HelloRequest, opts ...client.CallOption) (*HelloResponse, error) {
+	out := new(HelloResponse)
+	if err := c.c.Call(ctx, Demo_ServiceID, Demo_Hello_MethodID, in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *demoClient) HelloAsync(in *HelloRequest, opts ...client.CallOption) *client.Future {
+	return c.c.Go(Demo_ServiceID, Demo_Hello_MethodID, in, opts...)
+}
+
+func (c *demoClient) ParseHelloResponse(msg client.Message) (*HelloResponse, error) {
+	out := new(HelloResponse)
+	if err := client.ParseResponse(msg, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *demoClient) Chat(ctx context.Context, opts ...client.CallOption) (client.Stream, error) {
+	return c.c.NewStream(ctx, Demo_ServiceID, Demo_Chat_MethodID, opts...)
+}
@@ -2,61 +2,45 @@ package main
 
 import (
 	"fmt"
-	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/config"
 	"github.com/treeforest/gos/demo/pb"
 	"github.com/treeforest/gos/transport"
 	"github.com/treeforest/logger"
+	"io"
 )
 
 // 逻辑实现
-type Logic struct {}
+type Logic struct{}
 
 // Test Handle
-func (l *Logic) Hello(req *demo.HelloRequest) *demo.HelloResponse{
-	log.Debugf("Name: %s", req.Name)
+func (l *Logic) Hello(req transport.Request, in *demo.HelloRequest) (*demo.HelloResponse, error) {
+	// 读取客户端的数据
+	log.Debugf("serviceID=%d, methodID=%d, Name: %s", req.GetServiceID(), req.GetMethodID(), in.Name)
 	resp := new(demo.HelloResponse)
-	resp.Ret = fmt.Sprintf("Hello %s.", req.Name)
-	return resp
-}
-
-// 实例句柄
-var m_handle = &Logic{}
-
-// 路由实现
-type HelloRouter struct {
-	transport.BaseRouter
-}
-
-func (r *HelloRouter) PreHandle(req transport.Request) {
-	log.Debug("PreHandle")
+	resp.Ret = fmt.Sprintf("Hello %s.", in.Name)
+	return resp, nil
 }
 
-// Test Handle
-func (r *HelloRouter) Handle(req transport.Request) {
-	// 读取客户端的数据
-	log.Debugf("serviceID=%d, methodID=%d", req.GetServiceID(), req.GetMethodID())
-
-	switch req.GetMethodID() {
-	case uint32(demo.Event_Hello):
-		var resp *demo.HelloResponse
-		r := new(demo.HelloRequest)
-
-		if err := proto.Unmarshal(req.GetContext().GetData(), r); err != nil {
-			log.Error("Say request unmarshal error: ", err)
-			resp = new(demo.HelloResponse)
-			resp.Ret = "SayRequest unmarshal error."
-		} else {
-			resp = m_handle.Hello(r)
+// 双向流，客户端结束发送后返回
+func (l *Logic) Chat(s transport.ServerStream) error {
+	for {
+		in := new(demo.HelloRequest)
+		if err := s.Recv(in); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
 		}
 
-		data, _ := proto.Marshal(resp)
-		req.GetConnection().Send(req.GetContext(), data)
+		resp := new(demo.HelloResponse)
+		resp.Ret = fmt.Sprintf("Hello %s.", in.Name)
+		if err := s.Send(resp); err != nil {
+			return err
+		}
 	}
 }
 
-func (r *HelloRouter) PostHandle(req transport.Request) {
-	log.Debug("PostHandle")
-}
+// 实例句柄
+var m_handle = &Logic{}
 
 func OnConnStart(c transport.Connection) {
 	log.Debug("OnConnStart")
@@ -69,13 +53,21 @@ func OnConnStop(c transport.Connection) {
 func main() {
 	log.SetFileLogger()
 
-	s := transport.NewServer("[Demo]")
+	s := transport.NewServer(
+		transport.WithName("[Demo]"),
+		transport.WithAddress(config.ServerConfig.Host, config.ServerConfig.TcpPort),
+		transport.WithMaxConn(config.ServerConfig.MaxConn),
+		transport.WithMaxPackageSize(config.ServerConfig.MaxPackageSize),
+		transport.WithWorkerPoolSize(config.ServerConfig.WorkerPoolSize),
+		transport.WithMaxWorkerTaskLen(config.ServerConfig.MaxWorkerTaskLen),
+		transport.WithOrderedDispatch(transport.ShardByConn),
+	)
 
 	s.SetOnConnStartFunc(OnConnStart)
 	s.SetOnConnStopFunc(OnConnStop)
 
-	// 添加router
-	s.RegisterRouter(uint32(demo.ServiceID_demo), &HelloRouter{})
+	// 注册 Demo 服务
+	demo.RegisterDemoServer(s, m_handle)
 
 	s.Serve()
 }
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"github.com/treeforest/gos/protoc-gen-gos/gos"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	transportPackage = protogen.GoImportPath("github.com/treeforest/gos/transport")
+	clientPackage    = protogen.GoImportPath("github.com/treeforest/gos/client")
+	contextPackage   = protogen.GoImportPath("context")
+)
+
+// 生成 xxx_gos.pb.go 文件
+func generateFile(gen *protogen.Plugin, file *protogen.File) error {
+	filename := file.GeneratedFilenamePrefix + "_gos.pb.go"
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+
+	g.P("// Code generated by protoc-gen-gos. DO NOT EDIT.")
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+
+	for _, service := range file.Services {
+		if err := generateService(g, service); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 获取服务id
+func serviceID(service *protogen.Service) (uint32, error) {
+	opts := service.Desc.Options()
+	if opts == nil || !proto.HasExtension(opts, gos.E_ServiceId) {
+		return 0, fmt.Errorf("service %s: option (gos.service_id) is required", service.Desc.FullName())
+	}
+	return proto.GetExtension(opts, gos.E_ServiceId).(uint32), nil
+}
+
+// 获取方法id
+func methodID(method *protogen.Method) (uint32, error) {
+	opts := method.Desc.Options()
+	if opts == nil || !proto.HasExtension(opts, gos.E_MethodId) {
+		return 0, fmt.Errorf("method %s: option (gos.method_id) is required", method.Desc.FullName())
+	}
+	return proto.GetExtension(opts, gos.E_MethodId).(uint32), nil
+}
+
+func generateService(g *protogen.GeneratedFile, service *protogen.Service) error {
+	sid, err := serviceID(service)
+	if err != nil {
+		return err
+	}
+
+	name := service.GoName
+	methodIDs := make(map[uint32]string)
+
+	// 服务id与方法id常量
+	g.P("// ", name, " 服务的服务id与方法id")
+	g.P("const (")
+	g.P(name, "_ServiceID uint32 = ", sid)
+	for _, method := range service.Methods {
+		mid, err := methodID(method)
+		if err != nil {
+			return err
+		}
+		if other, ok := methodIDs[mid]; ok {
+			return fmt.Errorf("method %s: method_id %d is already used by %s", method.Desc.FullName(), mid, other)
+		}
+		methodIDs[mid] = method.GoName
+
+		g.P(name, "_", method.GoName, "_MethodID uint32 = ", mid)
+	}
+	g.P(")")
+	g.P()
+
+	generateServer(g, service)
+	generateClient(g, service)
+	return nil
+}
+
+// 生成服务端接口与注册函数
+func generateServer(g *protogen.GeneratedFile, service *protogen.Service) {
+	serverName := service.GoName + "Server"
+
+	g.P("// ", serverName, " 为 ", service.GoName, " 服务的服务端接口")
+	g.P("type ", serverName, " interface {")
+	for _, method := range service.Methods {
+		if isStreaming(method) {
+			g.P(method.Comments.Leading, method.GoName, "(s ", transportPackage.Ident("ServerStream"), ") error")
+			continue
+		}
+		g.P(method.Comments.Leading, method.GoName, "(req ", transportPackage.Ident("Request"),
+			", in *", method.Input.GoIdent, ") (*", method.Output.GoIdent, ", error)")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// 将 ", service.GoName, " 服务的处理函数注册到 Server")
+	g.P("func Register", serverName, "(s ", transportPackage.Ident("Server"), ", srv ", serverName, ") {")
+	for _, method := range service.Methods {
+		register := "RegisterHandler"
+		if isStreaming(method) {
+			register = "RegisterStreamHandler"
+		}
+		g.P("s.", register, "(", service.GoName, "_ServiceID, ",
+			service.GoName, "_", method.GoName, "_MethodID, srv.", method.GoName, ")")
+	}
+	g.P("}")
+	g.P()
+}
+
+// 生成客户端代理
+func generateClient(g *protogen.GeneratedFile, service *protogen.Service) {
+	clientName := service.GoName + "Client"
+	implName := unexport(clientName)
+
+	g.P("// ", clientName, " 为 ", service.GoName, " 服务的客户端代理")
+	g.P("type ", clientName, " interface {")
+	for i, method := range service.Methods {
+		if i > 0 {
+			g.P()
+		}
+		if isStreaming(method) {
+			g.P(method.Comments.Leading, "// 流式调用，发送 ", method.Input.GoIdent.GoName, "，接收 ", method.Output.GoIdent.GoName)
+			g.P(method.GoName, "(ctx ", contextPackage.Ident("Context"),
+				", opts ...", clientPackage.Ident("CallOption"), ") (", clientPackage.Ident("Stream"), ", error)")
+			continue
+		}
+		g.P(method.Comments.Leading, method.GoName, "(ctx ", contextPackage.Ident("Context"),
+			", in *", method.Input.GoIdent, ", opts ...", clientPackage.Ident("CallOption"), ") (*", method.Output.GoIdent, ", error)")
+		g.P()
+		g.P("// 异步调用 ", method.GoName, "，通过 Parse", method.GoName, "Response 解析 Future 的结果")
+		g.P(method.GoName, "Async(in *", method.Input.GoIdent, ", opts ...", clientPackage.Ident("CallOption"), ") *", clientPackage.Ident("Future"))
+		g.P()
+		g.P("// 解析 ", method.GoName, " 的响应")
+		g.P("Parse", method.GoName, "Response(msg ", clientPackage.Ident("Message"),
+			") (*", method.Output.GoIdent, ", error)")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("type ", implName, " struct {")
+	g.P("c ", clientPackage.Ident("Client"))
+	g.P("}")
+	g.P()
+
+	g.P("func New", clientName, "(c ", clientPackage.Ident("Client"), ") ", clientName, " {")
+	g.P("return &", implName, "{c: c}")
+	g.P("}")
+	g.P()
+
+	for _, method := range service.Methods {
+		if isStreaming(method) {
+			g.P("func (c *", implName, ") ", method.GoName, "(ctx ", contextPackage.Ident("Context"),
+				", opts ...", clientPackage.Ident("CallOption"), ") (", clientPackage.Ident("Stream"), ", error) {")
+			g.P("return c.c.NewStream(ctx, ", service.GoName, "_ServiceID, ", service.GoName, "_", method.GoName, "_MethodID, opts...)")
+			g.P("}")
+			g.P()
+			continue
+		}
+
+		g.P("func (c *", implName, ") ", method.GoName, "(ctx ", contextPackage.Ident("Context"),
+			", in *", method.Input.GoIdent, ", opts ...", clientPackage.Ident("CallOption"), ") (*", method.Output.GoIdent, ", error) {")
+		g.P("out := new(", method.Output.GoIdent, ")")
+		g.P("if err := c.c.Call(ctx, ", service.GoName, "_ServiceID, ", service.GoName, "_", method.GoName, "_MethodID, in, out, opts...); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return out, nil")
+		g.P("}")
+		g.P()
+
+		g.P("func (c *", implName, ") ", method.GoName, "Async(in *", method.Input.GoIdent, ", opts ...", clientPackage.Ident("CallOption"), ") *", clientPackage.Ident("Future"), " {")
+		g.P("return c.c.Go(", service.GoName, "_ServiceID, ", service.GoName, "_", method.GoName, "_MethodID, in, opts...)")
+		g.P("}")
+		g.P()
+
+		g.P("func (c *", implName, ") Parse", method.GoName, "Response(msg ", clientPackage.Ident("Message"),
+			") (*", method.Output.GoIdent, ", error) {")
+		g.P("out := new(", method.Output.GoIdent, ")")
+		g.P("if err := ", clientPackage.Ident("ParseResponse"), "(msg, out); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return out, nil")
+		g.P("}")
+		g.P()
+	}
+}
+
+// 是否为流式方法(服务端流、客户端流或双向流)
+func isStreaming(method *protogen.Method) bool {
+	return method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer()
+}
+
+func unexport(s string) string {
+	if s == "" {
+		return s
+	}
+	return string(s[0]|0x20) + s[1:]
+}
@@ -0,0 +1,35 @@
+// protoc-gen-gos 根据 proto 文件中的 service 定义生成 gos 的服务端接口、
+// 注册函数以及客户端代理。服务id与方法id通过 gos.proto 中的选项指定：
+//
+//	import "gos.proto";
+//
+//	service Demo {
+//	    option (gos.service_id) = 1;
+//	    rpc Hello (HelloRequest) returns (HelloResponse) {
+//	        option (gos.method_id) = 1;
+//	    }
+//	}
+//
+// 使用方式：
+//
+//	protoc -I . -I $GOPATH/src/github.com/treeforest/gos/protoc-gen-gos/gos \
+//	    --go_out=. --gos_out=. demo.proto
+package main
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+func main() {
+	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+		for _, f := range gen.Files {
+			if !f.Generate || len(f.Services) == 0 {
+				continue
+			}
+			if err := generateFile(gen, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
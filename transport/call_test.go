@@ -0,0 +1,71 @@
+package transport
+
+import (
+	gocontext "context"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/treeforest/gos/client"
+	"testing"
+	"time"
+)
+
+// 测试请求与响应按序列号匹配
+func TestCall(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	// 处理耗时为请求的毫秒数，原样返回
+	s.RegisterHandler(9, 1, func(req Request, in *wrappers.UInt32Value) (*wrappers.UInt32Value, error) {
+		time.Sleep(time.Duration(in.Value) * time.Millisecond)
+		return in, nil
+	})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Second*3)
+	defer cancel()
+
+	// 同一服务/方法的多个请求同时发出
+	delays := []uint32{50, 40, 30, 20, 10}
+	futures := make([]*client.Future, len(delays))
+	for i, delay := range delays {
+		futures[i] = c.Go(9, 1, &wrappers.UInt32Value{Value: delay})
+	}
+	for i, f := range futures {
+		out := new(wrappers.UInt32Value)
+		if err := f.Parse(ctx, out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Value != delays[i] {
+			t.Errorf("future %d value = %d, want %d", i, out.Value, delays[i])
+		}
+	}
+
+	// 返回码不为 SUCCESS 时返回错误
+	if err := c.Call(ctx, 8, 1, &wrappers.UInt32Value{}, new(wrappers.UInt32Value)); err == nil {
+		t.Error("call unknown service should fail")
+	}
+
+	// 超时后到达的响应被丢弃
+	timeout, cancelTimeout := gocontext.WithTimeout(ctx, time.Millisecond*50)
+	err := c.Call(timeout, 9, 1, &wrappers.UInt32Value{Value: 200}, new(wrappers.UInt32Value))
+	cancelTimeout()
+	if err != gocontext.DeadlineExceeded {
+		t.Errorf("call error = %v, want %v", err, gocontext.DeadlineExceeded)
+	}
+	out := new(wrappers.UInt32Value)
+	if err := c.Call(ctx, 9, 1, &wrappers.UInt32Value{Value: 1}, out); err != nil || out.Value != 1 {
+		t.Errorf("call after timeout = %v, %v", out, err)
+	}
+	if msg := recvTimeout(c, time.Millisecond*100); msg != nil {
+		t.Errorf("recv message %v, want nothing", msg.GetContext())
+	}
+
+	// 链接断开时等待中的调用返回 ErrClosed
+	f := c.Go(9, 1, &wrappers.UInt32Value{Value: 500})
+	time.Sleep(time.Millisecond * 100)
+	s.GetConnManager().ClearAllConn()
+	if _, err := f.Wait(ctx); err != client.ErrClosed {
+		t.Errorf("wait error = %v, want %v", err, client.ErrClosed)
+	}
+}
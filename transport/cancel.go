@@ -0,0 +1,84 @@
+package transport
+
+import (
+	gocontext "context"
+	"sync"
+	"time"
+)
+
+/*
+	处理中且可被客户端取消的请求
+	每个链接创建时新建
+*/
+type callTable struct {
+	lock  sync.Mutex
+	calls map[uint32]*inflightCall
+}
+
+func newCallTable() *callTable {
+	return &callTable{calls: make(map[uint32]*inflightCall)}
+}
+
+// 可被客户端取消的请求
+type inflightCall struct {
+	table  *callTable
+	seq    uint32
+	cancel gocontext.CancelFunc
+}
+
+// 取消序列号为 seq 的请求
+func (t *callTable) cancel(seq uint32) {
+	t.lock.Lock()
+	call, ok := t.calls[seq]
+	if ok {
+		delete(t.calls, seq)
+	}
+	t.lock.Unlock()
+
+	if ok {
+		call.cancel()
+	}
+}
+
+// 请求处理完毕，释放 Go context 的资源，不再接受取消
+func (call *inflightCall) release() {
+	call.cancel()
+	if call.seq == 0 {
+		return
+	}
+
+	t := call.table
+	t.lock.Lock()
+	// 客户端可能重复使用序列号，只删除自己
+	if t.calls[call.seq] == call {
+		delete(t.calls, call.seq)
+	}
+	t.lock.Unlock()
+}
+
+/*
+	为请求创建 Go context，链接停止时被取消
+	请求携带超时时间时，超时后被取消；请求携带序列号时，可被客户端的 CANCEL 消息取消
+*/
+func (c *connection) bindContext(r *request) {
+	timeout := r.ctx.GetTimeout()
+	seq := r.ctx.GetSeq()
+	if timeout == 0 && seq == 0 {
+		r.goctx = c.ctx
+		return
+	}
+
+	call := &inflightCall{table: c.calls, seq: seq}
+	if timeout > 0 {
+		r.goctx, call.cancel = gocontext.WithTimeout(c.ctx, time.Duration(timeout)*time.Millisecond)
+	} else {
+		r.goctx, call.cancel = gocontext.WithCancel(c.ctx)
+	}
+	r.call = call
+
+	if seq != 0 {
+		c.calls.lock.Lock()
+		c.calls.calls[seq] = call
+		c.calls.lock.Unlock()
+	}
+}
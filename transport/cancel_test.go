@@ -0,0 +1,125 @@
+package transport
+
+import (
+	gocontext "context"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/status"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试请求的 Go context 在客户端取消、超时及链接断开时被取消
+func TestRequestContext(t *testing.T) {
+	done := make(chan error, 1)
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	// 等待请求的 Go context 被取消
+	s.RegisterHandler(9, 1, func(req Request, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+		<-req.Context().Done()
+		done <- req.Context().Err()
+		return nil, req.Context().Err()
+	})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+
+	wait := func(want error) {
+		select {
+		case err := <-done:
+			if err != want {
+				t.Errorf("request context error = %v, want %v", err, want)
+			}
+		case <-time.After(time.Second * 3):
+			t.Fatalf("request context is not done, want %v", want)
+		}
+	}
+
+	// 客户端取消
+	f := c.Go(9, 1, &wrappers.StringValue{})
+	time.Sleep(time.Millisecond * 100)
+	f.Cancel()
+	if _, err := f.Wait(gocontext.Background()); err != gocontext.Canceled {
+		t.Errorf("wait error = %v, want %v", err, gocontext.Canceled)
+	}
+	wait(gocontext.Canceled)
+
+	// 请求超时，处理函数返回的 ctx.Err() 回复为 ERR_DEADLINE_EXCEEDED
+	f = c.Go(9, 1, &wrappers.StringValue{}, client.Timeout(time.Millisecond*100))
+	wait(gocontext.DeadlineExceeded)
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Second*3)
+	defer cancel()
+	err := f.Parse(ctx, new(wrappers.StringValue))
+	if code := status.CodeOf(err); code != status.Code(context.Code_ERR_DEADLINE_EXCEEDED) {
+		t.Errorf("timeout request error = %v, want %s", err, context.Code_ERR_DEADLINE_EXCEEDED)
+	}
+
+	// Call 在 ctx 结束时返回，服务器随后取消请求
+	short, cancelShort := gocontext.WithTimeout(ctx, time.Millisecond*100)
+	defer cancelShort()
+	if err := c.Call(short, 9, 1, &wrappers.StringValue{}, new(wrappers.StringValue)); err != gocontext.DeadlineExceeded {
+		t.Errorf("call error = %v, want %v", err, gocontext.DeadlineExceeded)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second * 3):
+		t.Fatal("request context is not done after call returned")
+	}
+
+	// 链接断开
+	c.Go(9, 1, &wrappers.StringValue{})
+	time.Sleep(time.Millisecond * 100)
+	s.GetConnManager().ClearAllConn()
+	wait(gocontext.Canceled)
+}
+
+// 测试排队期间已超时的请求不再交给处理函数
+func TestShedExpiredRequest(t *testing.T) {
+	var handled int32
+	s := NewServer(WithAddress("127.0.0.1", 0), WithWorkerPoolSize(1))
+	s.RegisterHandler(9, 1, func(req Request, in *wrappers.UInt32Value) (*wrappers.UInt32Value, error) {
+		atomic.AddInt32(&handled, 1)
+		time.Sleep(time.Duration(in.Value) * time.Millisecond)
+		return in, nil
+	})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Second*3)
+	defer cancel()
+
+	// 第一个请求占用唯一的worker，第二个请求排队期间超时
+	busy := c.Go(9, 1, &wrappers.UInt32Value{Value: 300})
+	expired := c.Go(9, 1, &wrappers.UInt32Value{}, client.Timeout(time.Millisecond*50))
+
+	if err := busy.Parse(ctx, new(wrappers.UInt32Value)); err != nil {
+		t.Fatal(err)
+	}
+	err := expired.Parse(ctx, new(wrappers.UInt32Value))
+	if code := status.CodeOf(err); code != status.Code(context.Code_ERR_DEADLINE_EXCEEDED) {
+		t.Errorf("expired request error = %v, want %s", err, context.Code_ERR_DEADLINE_EXCEEDED)
+	}
+	if n := atomic.LoadInt32(&handled); n != 1 {
+		t.Errorf("handled = %d, want 1", n)
+	}
+
+	// 丢弃的请求计入 PriorityStats，回复先于计数，稍等片刻
+	var shed uint64
+	for i := 0; i < 10 && shed == 0; i++ {
+		time.Sleep(time.Millisecond * 10)
+		for _, stats := range s.PriorityStats() {
+			if stats.Priority == context.Priority_NORMAL {
+				shed = stats.Shed
+			}
+		}
+	}
+	if shed != 1 {
+		t.Errorf("shed = %d, want 1", shed)
+	}
+}
@@ -0,0 +1,62 @@
+// Package codec 实现数据包的封包、拆包，用于处理流式链接中的粘包问题，服务端与客户端共用。
+//
+// 提供三种包格式：
+//
+//	Legacy    dataLen(4) + checkSum(4)，小端序，与早期版本兼容
+//	Versioned magic(2) + version(1) + flags(1) + seq(4) + dataLen(4) + checkSum(4)，大端序
+//	Varint    uvarint(dataLen) + data，无校验和，包头最短
+//
+// 服务端与客户端需使用相同的包格式。Versioned 格式可在 flags 中记录压缩算法及是否加密，
+// 封包时按 flags 压缩、加密数据，拆包时透明解密、解压。
+package codec
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	// 校验和错误，数据包已被完整读出，可以继续读取下一个数据包
+	ErrChecksum = errors.New("codec: checksum mismatch")
+
+	// 数据包超出最大大小
+	ErrTooLarge = errors.New("codec: package too large")
+
+	// 包头的 magic 不匹配
+	ErrMagic = errors.New("codec: bad magic")
+
+	// 不支持的协议版本
+	ErrVersion = errors.New("codec: unsupported version")
+)
+
+// 数据包
+type Frame struct {
+	// 标志位，仅 Versioned 格式支持，低4位为数据的压缩算法(Compression)，FlagEncrypted 表示数据已加密
+	Flags uint8
+
+	// 序列号，仅 Versioned 格式支持
+	Seq uint32
+
+	// 数据包的内容
+	Data []byte
+}
+
+/*
+	数据包的封包、拆包
+	直接连接链接中的数据流，用于处理粘包问题
+*/
+type Packer interface {
+	// 封包方法
+	Pack(f *Frame) ([]byte, error)
+
+	// 拆包方法，从 r 中读取一个完整的数据包
+	Unpack(r io.Reader) (*Frame, error)
+}
+
+// 检查数据包的大小，maxPackageSize 为0时不限制
+func checkSize(dataLen, maxPackageSize uint32) error {
+	if maxPackageSize > 0 && dataLen > maxPackageSize {
+		return ErrTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package codec
+
+import (
+	"bytes"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/context"
+	"net"
+	"testing"
+	"time"
+)
+
+var packers = map[string]func(maxPackageSize uint32) Packer{
+	"Legacy":    NewLegacyPacker,
+	"Versioned": NewVersionedPacker,
+	"Varint":    NewVarintPacker,
+}
+
+// 测试封包、拆包
+func TestDataPack(t *testing.T) {
+	for name, newPacker := range packers {
+		t.Run(name, func(t *testing.T) {
+			testDataPack(t, newPacker(4096))
+		})
+	}
+}
+
+func testDataPack(t *testing.T, pack Packer) {
+	/*
+		模拟服务端
+	*/
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("transport listen error: %v", err)
+	}
+	defer listener.Close()
+
+	recv := make(chan *context.Context, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Errorf("transport accept error: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		// 拆包过程
+		for i := 0; i < 2; i++ {
+			f, err := pack.Unpack(conn)
+			if err != nil {
+				t.Errorf("transport unpack error: %v", err)
+				return
+			}
+
+			ctx := new(context.Context)
+			proto.Unmarshal(f.Data, ctx)
+
+			// 读取数据完毕
+			t.Logf("--->Recv context: %v", ctx)
+			recv <- ctx
+		}
+	}()
+
+	/*
+		模拟客户端
+	*/
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("client dial error: %v", err)
+	}
+	defer conn.Close()
+
+	// 模拟粘包过程,封装两个msg一同发送
+	ctx1 := new(context.Context)
+	ctx1.ServiceId = 1
+	ctx1.MethodId = 2
+	ctx1.Data = []byte{'h', 'e', 'l', 'l', 'o'}
+	data1, _ := proto.Marshal(ctx1)
+	buf1, err := pack.Pack(&Frame{Data: data1})
+	if err != nil {
+		t.Fatalf("client pack msg1 error: %v", err)
+	}
+
+	ctx2 := new(context.Context)
+	ctx2.ServiceId = 2
+	ctx2.MethodId = 12
+	ctx2.Data = []byte{'w', 'o', 'r', 'l', 'd'}
+	data2, _ := proto.Marshal(ctx2)
+	buf2, err := pack.Pack(&Frame{Data: data2})
+	if err != nil {
+		t.Fatalf("client pack msg2 error: %v", err)
+	}
+
+	// 模拟粘包
+	buf := append(buf1, buf2...)
+
+	// 一次性写
+	conn.Write(buf)
+
+	for _, want := range []*context.Context{ctx1, ctx2} {
+		select {
+		case ctx := <-recv:
+			if !proto.Equal(ctx, want) {
+				t.Errorf("recv context = %v, want %v", ctx, want)
+			}
+		case <-time.After(time.Second * 3):
+			t.Fatal("recv context timeout")
+		}
+	}
+}
+
+// 测试拆包的错误处理
+func TestUnpackError(t *testing.T) {
+	for name, newPacker := range packers {
+		t.Run(name, func(t *testing.T) {
+			p := newPacker(8)
+
+			// 超出最大大小
+			buf, _ := p.Pack(&Frame{Data: make([]byte, 9)})
+			if _, err := p.Unpack(bytes.NewReader(buf)); err != ErrTooLarge {
+				t.Errorf("unpack error = %v, want %v", err, ErrTooLarge)
+			}
+
+			if name == "Varint" {
+				// Varint 格式不携带校验和
+				return
+			}
+
+			// 校验和错误后，可以继续读取下一个数据包
+			bad, _ := p.Pack(&Frame{Data: []byte("bad")})
+			bad[len(bad)-1] ^= 0xff
+			good, _ := p.Pack(&Frame{Data: []byte("good")})
+
+			r := bytes.NewReader(append(bad, good...))
+			if _, err := p.Unpack(r); err != ErrChecksum {
+				t.Errorf("unpack error = %v, want %v", err, ErrChecksum)
+			}
+			if f, err := p.Unpack(r); err != nil || string(f.Data) != "good" {
+				t.Errorf("unpack after checksum error = %v, %v", f, err)
+			}
+		})
+	}
+}
+
+// 测试 Versioned 格式的包头
+func TestVersionedPacker(t *testing.T) {
+	p := NewVersionedPacker(0)
+
+	buf, _ := p.Pack(&Frame{Flags: 0x3, Seq: 42, Data: []byte("hello")})
+	f, err := p.Unpack(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Flags != 0x3 || f.Seq != 42 || string(f.Data) != "hello" {
+		t.Errorf("unpack frame = %+v", f)
+	}
+
+	// 使用 Legacy 格式的数据无法通过 magic 校验
+	legacy, _ := NewLegacyPacker(0).Pack(&Frame{Data: []byte("hello world")})
+	if _, err := p.Unpack(bytes.NewReader(legacy)); err != ErrMagic {
+		t.Errorf("unpack error = %v, want %v", err, ErrMagic)
+	}
+
+	buf[2] = Version + 1
+	if _, err := p.Unpack(bytes.NewReader(buf)); err != ErrVersion {
+		t.Errorf("unpack error = %v, want %v", err, ErrVersion)
+	}
+}
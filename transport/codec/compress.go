@@ -0,0 +1,154 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"sync"
+)
+
+// 压缩算法，记录在 Versioned 格式包头 flags 的低4位
+type Compression uint8
+
+const (
+	// 不压缩
+	CompressNone Compression = iota
+
+	// gzip，压缩率高，CPU 开销较大
+	CompressGzip
+
+	// snappy，速度快，压缩率较低
+	CompressSnappy
+
+	// zstd，兼顾速度与压缩率
+	CompressZstd
+)
+
+// flags 中表示压缩算法的位
+const FlagCompressMask uint8 = 0x0f
+
+// 不支持的压缩算法
+var ErrCompression = errors.New("codec: unsupported compression")
+
+func (c Compression) String() string {
+	switch c {
+	case CompressNone:
+		return "none"
+	case CompressGzip:
+		return "gzip"
+	case CompressSnappy:
+		return "snappy"
+	case CompressZstd:
+		return "zstd"
+	}
+	return fmt.Sprintf("compression(%d)", uint8(c))
+}
+
+// 是否为支持的压缩算法
+func (c Compression) Valid() bool {
+	return c <= CompressZstd
+}
+
+// 包格式是否能够在包头中记录压缩算法，目前仅 Versioned 格式支持
+func SupportsCompression(p Packer) bool {
+	_, ok := p.(*versionedPacker)
+	return ok
+}
+
+var (
+	// EncodeAll 可并发调用
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+
+	// 解压时需要限制输出大小，使用流式接口，每个 decoder 同一时间只能被一个 goroutine 使用
+	zstdDecoderPool = sync.Pool{
+		New: func() interface{} {
+			d, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+			return d
+		},
+	}
+)
+
+// 使用 c 压缩数据
+func compress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case CompressNone:
+		return data, nil
+
+	case CompressGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+
+	case CompressSnappy:
+		return snappy.Encode(nil, data), nil
+
+	case CompressZstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}
+	return nil, ErrCompression
+}
+
+// 使用 c 解压数据，解压后的大小超过 maxSize 时返回 ErrTooLarge，maxSize 为0时不限制。
+// 压缩数据很小而解压后极大(zip bomb)时，最多只会解压出 maxSize+1 字节
+func decompress(c Compression, data []byte, maxSize uint32) ([]byte, error) {
+	switch c {
+	case CompressNone:
+		return data, nil
+
+	case CompressGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return readLimited(r, maxSize)
+
+	case CompressSnappy:
+		// snappy 在数据头部记录了解压后的大小，可在解压前检查
+		n, err := snappy.DecodedLen(data)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(n) > 1<<32-1 || checkSize(uint32(n), maxSize) != nil {
+			return nil, ErrTooLarge
+		}
+		return snappy.Decode(nil, data)
+
+	case CompressZstd:
+		d := zstdDecoderPool.Get().(*zstd.Decoder)
+		defer zstdDecoderPool.Put(d)
+		if err := d.Reset(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		return readLimited(d, maxSize)
+	}
+	return nil, ErrCompression
+}
+
+// 读取 r 中的全部数据，超过 maxSize 时返回 ErrTooLarge
+func readLimited(r io.Reader, maxSize uint32) ([]byte, error) {
+	if maxSize > 0 {
+		r = io.LimitReader(r, int64(maxSize)+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) > 1<<32-1 {
+		return nil, ErrTooLarge
+	}
+	if err := checkSize(uint32(len(data)), maxSize); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
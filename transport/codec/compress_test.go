@@ -0,0 +1,61 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+var compressions = []Compression{CompressGzip, CompressSnappy, CompressZstd}
+
+// 测试压缩数据的封包、拆包
+func TestCompression(t *testing.T) {
+	p := NewVersionedPacker(4096)
+	data := bytes.Repeat([]byte("hello world "), 100)
+
+	for _, c := range compressions {
+		t.Run(c.String(), func(t *testing.T) {
+			buf, err := p.Pack(&Frame{Flags: uint8(c), Data: data})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(buf) >= versionedHeadLen+len(data) {
+				t.Errorf("packed len = %d, data is not compressed", len(buf))
+			}
+
+			f, err := p.Unpack(bytes.NewReader(buf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if Compression(f.Flags&FlagCompressMask) != c || !bytes.Equal(f.Data, data) {
+				t.Errorf("unpack flags = %d, data len = %d", f.Flags, len(f.Data))
+			}
+		})
+	}
+
+	if _, err := p.Pack(&Frame{Flags: 0x0f, Data: data}); err != ErrCompression {
+		t.Errorf("pack error = %v, want %v", err, ErrCompression)
+	}
+}
+
+// 测试解压后的大小受 maxPackageSize 限制
+func TestDecompressTooLarge(t *testing.T) {
+	const maxPackageSize = 64 << 10
+	bomb := make([]byte, 1<<20)
+
+	for _, c := range compressions {
+		t.Run(c.String(), func(t *testing.T) {
+			buf, err := NewVersionedPacker(0).Pack(&Frame{Flags: uint8(c), Data: bomb})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 压缩后的大小未超出限制，解压后超出
+			if len(buf) > maxPackageSize {
+				t.Fatalf("packed len = %d, want less than %d", len(buf), maxPackageSize)
+			}
+
+			if _, err := NewVersionedPacker(maxPackageSize).Unpack(bytes.NewReader(buf)); err != ErrTooLarge {
+				t.Errorf("unpack error = %v, want %v", err, ErrTooLarge)
+			}
+		})
+	}
+}
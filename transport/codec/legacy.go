@@ -0,0 +1,62 @@
+package codec
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// Legacy 格式的包头长度：dataLen uint32 (4字节) + checkSum uint32 (4字节)
+const legacyHeadLen = 8
+
+type legacyPacker struct {
+	// 数据包的最大大小
+	maxPackageSize uint32
+}
+
+// 创建 Legacy 格式的封包、拆包模块，maxPackageSize 为0时不限制数据包大小
+func NewLegacyPacker(maxPackageSize uint32) Packer {
+	return &legacyPacker{maxPackageSize: maxPackageSize}
+}
+
+func (p *legacyPacker) Pack(f *Frame) ([]byte, error) {
+	buf := make([]byte, legacyHeadLen+len(f.Data))
+
+	// 将数据包长度、校验码写入包头
+	binary.LittleEndian.PutUint32(buf[0:], uint32(len(f.Data)))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(f.Data))
+
+	// 将消息内容写入数据包
+	copy(buf[legacyHeadLen:], f.Data)
+
+	return buf, nil
+}
+
+func (p *legacyPacker) Unpack(r io.Reader) (*Frame, error) {
+	// 1、读取包头
+	head := make([]byte, legacyHeadLen)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+
+	dataLen := binary.LittleEndian.Uint32(head[0:])
+	checkSum := binary.LittleEndian.Uint32(head[4:])
+
+	// 2、判断dataLen是否符合要求的最大包长度
+	if err := checkSize(dataLen, p.maxPackageSize); err != nil {
+		return nil, err
+	}
+
+	// 3、根据dataLen将data读出来
+	f := &Frame{Data: make([]byte, dataLen)}
+	if _, err := io.ReadFull(r, f.Data); err != nil {
+		return nil, err
+	}
+
+	// 4、crc32校验
+	if checkSum != crc32.ChecksumIEEE(f.Data) {
+		return nil, ErrChecksum
+	}
+
+	return f, nil
+}
@@ -0,0 +1,190 @@
+package codec
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/nacl/secretbox"
+	"sync"
+)
+
+// flags 中表示数据已使用链接的会话密钥加密的位
+const FlagEncrypted uint8 = 0x10
+
+// 加密后的数据至少包含计数器(8)与认证码(16)
+const sealOverhead = 8 + secretbox.Overhead
+
+// 接收方记录的计数器窗口，乱序到达的数据包只要落在窗口内且未收到过即被接受
+const replayWindow = 1024
+
+// 链接中的角色，决定会话密钥派生出的发送密钥与接收密钥
+type Role uint8
+
+const (
+	RoleClient Role = iota
+	RoleServer
+)
+
+// 派生两个方向密钥的标签
+var (
+	labelClientToServer = []byte("gos client to server")
+	labelServerToClient = []byte("gos server to client")
+)
+
+var (
+	// 包格式不支持加密，或数据包已加密而未绑定加密模块
+	ErrEncryption = errors.New("codec: encryption is not available")
+
+	// 数据包解密失败，数据或包头被篡改、会话密钥不匹配
+	ErrDecrypt = errors.New("codec: message authentication failed")
+
+	// 数据包已收到过(重放)或已落后于接收窗口
+	ErrReplay = errors.New("codec: replayed message")
+)
+
+/*
+	链接的会话加密
+
+	会话密钥经 HMAC-SHA256 派生出客户端到服务器、服务器到客户端两个方向的密钥，一方发出的数据包无法被反射回该方。
+	每个数据包使用递增的64位计数器作为 nonce，计数器随密文发送；包头的 flags 与 seq 同样写入 nonce，被篡改时认证失败。
+	接收方记录最近 replayWindow 个计数器，重复的或过旧的计数器被拒绝
+*/
+type sessionCipher struct {
+	sendKey [32]byte
+	recvKey [32]byte
+
+	sendLock    sync.Mutex
+	sendCounter uint64
+
+	recvLock sync.Mutex
+	// 收到的最大计数器
+	recvMax uint64
+	// 最近 replayWindow 个计数器是否已收到，计数器 n 对应第 n%replayWindow 位
+	recvSeen [replayWindow / 64]uint64
+}
+
+// 为 Versioned 格式的封包、拆包模块绑定链接的会话密钥，返回的模块对带有 FlagEncrypted 标志的数据包加密、解密。
+// 每个链接使用独立的会话密钥，需为每个链接分别绑定，p 本身不受影响
+func WithSessionKey(p Packer, key []byte, role Role) (Packer, error) {
+	v, ok := p.(*versionedPacker)
+	if !ok || len(key) == 0 {
+		return nil, ErrEncryption
+	}
+
+	c := new(sessionCipher)
+	c2s, s2c := deriveKey(key, labelClientToServer), deriveKey(key, labelServerToClient)
+	if role == RoleClient {
+		c.sendKey, c.recvKey = c2s, s2c
+	} else {
+		c.sendKey, c.recvKey = s2c, c2s
+	}
+	return &versionedPacker{maxPackageSize: v.maxPackageSize, cipher: c}, nil
+}
+
+// 由会话密钥派生单个方向的密钥
+func deriveKey(key, label []byte) (out [32]byte) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(label)
+	copy(out[:], mac.Sum(nil))
+	return out
+}
+
+// 由计数器与包头的 flags、seq 组成 nonce
+func nonce(counter uint64, flags uint8, seq uint32) (n [24]byte) {
+	binary.BigEndian.PutUint64(n[0:], counter)
+	n[8] = flags
+	binary.BigEndian.PutUint32(n[9:], seq)
+	return n
+}
+
+// 加密数据，输出为计数器(8) + 密文
+func seal(c *sessionCipher, flags uint8, seq uint32, data []byte) ([]byte, error) {
+	if c == nil {
+		return nil, ErrEncryption
+	}
+
+	c.sendLock.Lock()
+	c.sendCounter++
+	counter := c.sendCounter
+	c.sendLock.Unlock()
+
+	out := make([]byte, 8, sealOverhead+len(data))
+	binary.BigEndian.PutUint64(out, counter)
+	n := nonce(counter, flags, seq)
+	return secretbox.Seal(out, data, &n, &c.sendKey), nil
+}
+
+// 解密数据，数据过短或认证失败时返回 ErrDecrypt，计数器重复或过旧时返回 ErrReplay
+func open(c *sessionCipher, flags uint8, seq uint32, data []byte) ([]byte, error) {
+	if c == nil {
+		return nil, ErrEncryption
+	}
+	if len(data) < sealOverhead {
+		return nil, ErrDecrypt
+	}
+
+	counter := binary.BigEndian.Uint64(data)
+	n := nonce(counter, flags, seq)
+	out, ok := secretbox.Open(nil, data[8:], &n, &c.recvKey)
+	if !ok {
+		return nil, ErrDecrypt
+	}
+
+	// 认证通过后才记录计数器，伪造的数据包无法推动接收窗口
+	if !c.accept(counter) {
+		return nil, ErrReplay
+	}
+	return out, nil
+}
+
+// 检查计数器未收到过且未落后于接收窗口，并记录该计数器
+func (c *sessionCipher) accept(counter uint64) bool {
+	c.recvLock.Lock()
+	defer c.recvLock.Unlock()
+
+	if counter == 0 {
+		return false
+	}
+
+	if counter > c.recvMax {
+		// 窗口前移，清除移出窗口的计数器对应的位
+		if counter-c.recvMax >= replayWindow {
+			c.recvSeen = [replayWindow / 64]uint64{}
+		} else {
+			for i := c.recvMax + 1; i < counter; i++ {
+				c.clear(i)
+			}
+		}
+		c.recvMax = counter
+		c.mark(counter)
+		return true
+	}
+
+	if c.recvMax-counter >= replayWindow || c.seen(counter) {
+		return false
+	}
+	c.mark(counter)
+	return true
+}
+
+func (c *sessionCipher) seen(counter uint64) bool {
+	i := counter % replayWindow
+	return c.recvSeen[i/64]&(1<<(i%64)) != 0
+}
+
+func (c *sessionCipher) mark(counter uint64) {
+	i := counter % replayWindow
+	c.recvSeen[i/64] |= 1 << (i % 64)
+}
+
+func (c *sessionCipher) clear(counter uint64) {
+	i := counter % replayWindow
+	c.recvSeen[i/64] &^= 1 << (i % 64)
+}
+
+// 包格式是否支持加密，目前仅 Versioned 格式支持
+func SupportsEncryption(p Packer) bool {
+	_, ok := p.(*versionedPacker)
+	return ok
+}
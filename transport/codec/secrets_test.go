@@ -0,0 +1,91 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+// 测试加密数据包的封包、拆包
+func TestSecrets(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	client, err := WithSessionKey(NewVersionedPacker(4096), key, RoleClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _ := WithSessionKey(NewVersionedPacker(4096), key, RoleServer)
+	if _, err := WithSessionKey(NewLegacyPacker(4096), key, RoleClient); err != ErrEncryption {
+		t.Errorf("legacy packer error = %v, want %v", err, ErrEncryption)
+	}
+
+	data := bytes.Repeat([]byte("hello world "), 100)
+	buf, err := client.Pack(&Frame{Flags: FlagEncrypted | uint8(CompressSnappy), Seq: 7, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf, []byte("hello")) {
+		t.Error("packed data is not encrypted")
+	}
+
+	f, err := server.Unpack(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Data, data) || f.Seq != 7 {
+		t.Errorf("unpack data len = %d seq = %d, want %d, %d", len(f.Data), f.Seq, len(data), 7)
+	}
+
+	// 重放的数据包被拒绝
+	if _, err := server.Unpack(bytes.NewReader(buf)); err != ErrReplay {
+		t.Errorf("replay error = %v, want %v", err, ErrReplay)
+	}
+
+	// 反射回发送方的数据包认证失败
+	if _, err := client.Unpack(bytes.NewReader(buf)); err != ErrDecrypt {
+		t.Errorf("reflect error = %v, want %v", err, ErrDecrypt)
+	}
+
+	// 篡改包头 seq 的数据包认证失败
+	buf, _ = client.Pack(&Frame{Flags: FlagEncrypted, Seq: 1, Data: data})
+	buf[7] = 2
+	if _, err := server.Unpack(bytes.NewReader(buf)); err != ErrDecrypt {
+		t.Errorf("tampered seq error = %v, want %v", err, ErrDecrypt)
+	}
+
+	// 未绑定会话密钥时无法拆包
+	if _, err := NewVersionedPacker(4096).Unpack(bytes.NewReader(buf)); err != ErrEncryption {
+		t.Errorf("unpack error = %v, want %v", err, ErrEncryption)
+	}
+
+	// 使用其它会话密钥加密的数据包认证失败
+	forged, _ := WithSessionKey(NewVersionedPacker(4096), bytes.Repeat([]byte{2}, 32), RoleClient)
+	buf, _ = forged.Pack(&Frame{Flags: FlagEncrypted, Data: data})
+	if _, err := server.Unpack(bytes.NewReader(buf)); err != ErrDecrypt {
+		t.Errorf("unpack error = %v, want %v", err, ErrDecrypt)
+	}
+}
+
+// 测试接收窗口内乱序到达的数据包被接受，重复或过旧的被拒绝
+func TestReplayWindow(t *testing.T) {
+	c := new(sessionCipher)
+	for _, n := range []uint64{2, 1, 5, 3} {
+		if !c.accept(n) {
+			t.Errorf("counter %d rejected", n)
+		}
+	}
+	for _, n := range []uint64{0, 1, 5} {
+		if c.accept(n) {
+			t.Errorf("counter %d accepted twice", n)
+		}
+	}
+
+	if !c.accept(5 + replayWindow) {
+		t.Errorf("counter %d rejected", 5+replayWindow)
+	}
+	// 4 落后于窗口，6 在窗口内且未收到过
+	if c.accept(4) {
+		t.Error("counter 4 behind the window accepted")
+	}
+	if !c.accept(6) {
+		t.Error("counter 6 in the window rejected")
+	}
+}
@@ -0,0 +1,56 @@
+package codec
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+type varintPacker struct {
+	// 数据包的最大大小
+	maxPackageSize uint32
+}
+
+// 创建 Varint 格式的封包、拆包模块，maxPackageSize 为0时不限制数据包大小。
+// 该格式不携带校验和，依赖传输层(TCP、TLS、KCP)保证数据完整
+func NewVarintPacker(maxPackageSize uint32) Packer {
+	return &varintPacker{maxPackageSize: maxPackageSize}
+}
+
+func (p *varintPacker) Pack(f *Frame) ([]byte, error) {
+	buf := make([]byte, binary.MaxVarintLen32+len(f.Data))
+	n := binary.PutUvarint(buf, uint64(len(f.Data)))
+	n += copy(buf[n:], f.Data)
+	return buf[:n], nil
+}
+
+func (p *varintPacker) Unpack(r io.Reader) (*Frame, error) {
+	dataLen, err := binary.ReadUvarint(byteReader{r})
+	if err != nil {
+		return nil, err
+	}
+	if dataLen > 1<<32-1 {
+		return nil, ErrTooLarge
+	}
+	if err := checkSize(uint32(dataLen), p.maxPackageSize); err != nil {
+		return nil, err
+	}
+
+	f := &Frame{Data: make([]byte, dataLen)}
+	if _, err := io.ReadFull(r, f.Data); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// 逐字节读取，避免读取到下一个数据包的内容
+type byteReader struct {
+	io.Reader
+}
+
+func (r byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r.Reader, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
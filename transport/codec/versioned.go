@@ -0,0 +1,105 @@
+package codec
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// Versioned 格式包头的 magic："GS"
+	Magic uint16 = 0x4753
+
+	// 当前的协议版本
+	Version uint8 = 1
+
+	// 包头长度：magic(2) + version(1) + flags(1) + seq(4) + dataLen(4) + checkSum(4)
+	versionedHeadLen = 16
+)
+
+type versionedPacker struct {
+	// 数据包的最大大小
+	maxPackageSize uint32
+
+	// 链接的会话加密，通过 WithSessionKey 绑定
+	cipher *sessionCipher
+}
+
+// 创建 Versioned 格式的封包、拆包模块，maxPackageSize 为0时不限制数据包大小
+func NewVersionedPacker(maxPackageSize uint32) Packer {
+	return &versionedPacker{maxPackageSize: maxPackageSize}
+}
+
+// 封包，flags 中记录了压缩算法时先压缩数据，带有 FlagEncrypted 标志时再加密
+func (p *versionedPacker) Pack(f *Frame) ([]byte, error) {
+	data, err := compress(Compression(f.Flags&FlagCompressMask), f.Data)
+	if err != nil {
+		return nil, err
+	}
+	if f.Flags&FlagEncrypted != 0 {
+		if data, err = seal(p.cipher, f.Flags, f.Seq, data); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := make([]byte, versionedHeadLen+len(data))
+
+	binary.BigEndian.PutUint16(buf[0:], Magic)
+	buf[2] = Version
+	buf[3] = f.Flags
+	binary.BigEndian.PutUint32(buf[4:], f.Seq)
+	binary.BigEndian.PutUint32(buf[8:], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[12:], crc32.ChecksumIEEE(data))
+	copy(buf[versionedHeadLen:], data)
+
+	return buf, nil
+}
+
+// 拆包，返回解密、解压后的数据，解压后的大小同样受 maxPackageSize 限制
+func (p *versionedPacker) Unpack(r io.Reader) (*Frame, error) {
+	head := make([]byte, versionedHeadLen)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+
+	// magic 或版本不匹配时无法确定数据包的边界，链接不可继续使用
+	if binary.BigEndian.Uint16(head[0:]) != Magic {
+		return nil, ErrMagic
+	}
+	if head[2] != Version {
+		return nil, ErrVersion
+	}
+
+	dataLen := binary.BigEndian.Uint32(head[8:])
+	if err := checkSize(dataLen, p.maxPackageSize); err != nil {
+		return nil, err
+	}
+
+	f := &Frame{
+		Flags: head[3],
+		Seq:   binary.BigEndian.Uint32(head[4:]),
+		Data:  make([]byte, dataLen),
+	}
+	if _, err := io.ReadFull(r, f.Data); err != nil {
+		return nil, err
+	}
+
+	if binary.BigEndian.Uint32(head[12:]) != crc32.ChecksumIEEE(f.Data) {
+		return nil, ErrChecksum
+	}
+
+	// 先解密，再解压
+	var err error
+	data := f.Data
+	if f.Flags&FlagEncrypted != 0 {
+		if data, err = open(p.cipher, f.Flags, f.Seq, data); err != nil {
+			return nil, err
+		}
+	}
+	if data, err = decompress(Compression(f.Flags&FlagCompressMask), data, p.maxPackageSize); err != nil {
+		return nil, err
+	}
+	f.Data = data
+
+	return f, nil
+}
@@ -1,14 +1,38 @@
 package transport
 
 import (
-	"errors"
+	gocontext "context"
 	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/codec"
 	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/stream"
 	"github.com/treeforest/logger"
 	"hash/crc32"
-	"io"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 链接关闭时，将待发送消息写出的最长等待时间
+const flushTimeout = time.Second * 3
+
+// 发送队列满时的处理策略
+type SendPolicy int
+
+const (
+	// 阻塞等待发送队列空闲，超时返回 ErrSendTimeout
+	SendBlock SendPolicy = iota
+
+	// 丢弃队列中最早的消息，放入新消息
+	SendDropOldest
+
+	// 丢弃新消息，返回 ErrSendQueueFull
+	SendDropNewest
+
+	// 断开处理过慢的链接，返回 ErrSendQueueFull
+	SendDisconnect
 )
 
 /*
@@ -16,23 +40,70 @@ import (
 */
 type connection struct {
 	// 当前链接隶属于那个server
-	tcpServer Server
+	server Server
 
-	// 当前链接的套接字
-	conn *net.TCPConn
+	// 当前链接的套接字(TCP、TLS、WebSocket 或 KCP)
+	conn net.Conn
 
 	// 链接的ID
 	connID uint32
 
+	// 保护 started、closed 状态及 sealed
+	lock sync.Mutex
+
+	// 读写goroutine是否已经启动
+	started bool
+
 	// 当前链接的状态
 	closed bool
 
+	// 保证 Stop 只执行一次
+	stopOnce sync.Once
+
+	// 空闲回收已开始关闭该链接(CAS)，避免每次检测都启动新的 Stop
+	reaping int32
+
 	// 告知当前链接已经退出/停止的channel(由reader告知writer)
-	existChan chan bool
+	existChan chan struct{}
 
-	// 无缓冲管道，用于读、写goroutine之间的消息通信
+	// writer 退出后关闭
+	writerDone chan struct{}
+
+	// 发送队列，用于业务goroutine与writer之间的消息通信
 	msgChan chan []byte
 
+	// 发送队列满时的处理策略及 SendBlock 策略下的最长等待时间
+	sendPolicy  SendPolicy
+	sendTimeout time.Duration
+
+	// 单次写操作的最长时间
+	writeTimeout time.Duration
+
+	// 最后一次收到完整数据包的时间(UnixNano)
+	lastActive int64
+
+	// 握手协商的压缩算法(codec.Compression)，由reader写入
+	compression uint32
+
+	// 数据大小达到该值时才压缩
+	compressThreshold uint32
+
+	// 绑定了会话密钥的封包、拆包模块，加密握手完成后设置
+	sealed DataPacker
+
+	// 链接的 Go context，链接停止时取消，是所有请求 Go context 的父 context
+	ctx    gocontext.Context
+	cancel gocontext.CancelFunc
+
+	// 处理中且可被客户端取消的请求
+	calls *callTable
+
+	// 处理中的流
+	streams *stream.Table
+
+	// 等待所有流式处理函数返回
+	streamWg sync.WaitGroup
+
 	// msgID和对应的处理业务的API关系
 	msgHandler MessageHandler
 
@@ -40,18 +111,30 @@ type connection struct {
 	propertyMap sync.Map
 }
 
-func NewConnection(tcpServer Server, conn *net.TCPConn, connID uint32, msgHandler MessageHandler) Connection {
-	c := globalPool.GetConnection()
-	c.tcpServer = tcpServer
+func NewConnection(server Server, conn net.Conn, connID uint32, msgHandler MessageHandler) Connection {
+	// 链接停止后对象可能仍被引用，每个链接使用新的对象
+	c := new(connection)
+	c.server = server
 	c.conn = conn
 	c.connID = connID
-	c.closed = false
 	c.msgHandler = msgHandler
-	c.existChan = make(chan bool)
-	c.msgChan = make(chan []byte)
+	c.existChan = make(chan struct{})
+	c.writerDone = make(chan struct{})
+
+	opts := server.GetOptions()
+	c.msgChan = make(chan []byte, opts.SendQueueSize)
+	c.sendPolicy = opts.SendPolicy
+	c.sendTimeout = opts.SendTimeout
+	c.writeTimeout = opts.WriteTimeout
+	c.compressThreshold = opts.CompressThreshold
+	c.ctx, c.cancel = gocontext.WithCancel(gocontext.Background())
+	c.calls = newCallTable()
+	c.streams = stream.NewTable()
+	atomic.StoreUint32(&c.compression, uint32(codec.CompressNone))
+	atomic.StoreInt64(&c.lastActive, time.Now().UnixNano())
 
 	// 将conn加入到connManager中
-	c.tcpServer.GetConnManager().Add(c)
+	c.server.GetConnManager().Add(c)
 
 	return c
 }
@@ -59,45 +142,67 @@ func NewConnection(tcpServer Server, conn *net.TCPConn, connID uint32, msgHandle
 func (c *connection) Start() {
 	log.Debugf("[Conn Start] ConnID = %d", c.connID)
 
+	c.lock.Lock()
+	if c.closed {
+		// 链接在启动之前已被停止
+		c.lock.Unlock()
+		return
+	}
+	c.started = true
+
 	// 启动从当前链接读数据的业务
 	go c.startReader()
 
 	// 启动从当前写数据的业务
 	go c.startWriter()
+	c.lock.Unlock()
 
 	// 链接之前执行的HOOk
-	c.tcpServer.CallOnConnStart(c)
+	c.server.CallOnConnStart(c)
 }
 
+// 停止链接：通知writer将已提交的消息写出，随后关闭套接字
 func (c *connection) Stop() {
-	log.Debugf("[Conn Stop] ConnID = %d", c.connID)
-	if c.closed {
-		return
-	}
-	c.closed = true
+	c.stopOnce.Do(func() {
+		log.Debugf("[Conn Stop] ConnID = %d", c.connID)
+
+		c.lock.Lock()
+		c.closed = true
+		started := c.started
+		c.lock.Unlock()
 
-	// 链接结束之前调用HOOK
-	c.tcpServer.CallOnConnStop(c)
+		// 取消所有请求的 Go context
+		c.cancel()
 
-	// 回收链接
-	c.conn.Close()
-	globalPool.PutTCPConn(c.conn)
+		// 链接结束之前调用HOOK
+		c.server.CallOnConnStop(c)
 
-	// 通知writer关闭
-	c.existChan <- true
+		// 退出所有分组
+		c.server.GetGroupManager().LeaveAll(c)
 
-	// 将当前链接从connManager中移除
-	c.tcpServer.GetConnManager().Remove(c)
+		// 通知writer关闭，并等待其写出剩余的消息。
+		// 设置写超时，避免writer阻塞在不读取数据的客户端上
+		c.conn.SetWriteDeadline(time.Now().Add(flushTimeout))
+		close(c.existChan)
+		if started {
+			<-c.writerDone
+		}
 
-	// 回收资源
-	close(c.existChan)
-	close(c.msgChan)
+		// 关闭链接，reader随之退出
+		c.conn.Close()
 
-	// 回收connection对象
-	globalPool.PutConnection(c)
+		// 将当前链接从connManager中移除
+		c.server.GetConnManager().Remove(c)
+	})
 }
 
-func (c *connection) GetTCPConnection() *net.TCPConn {
+func (c *connection) isClosed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.closed
+}
+
+func (c *connection) GetConn() net.Conn {
 	return c.conn
 }
 
@@ -105,10 +210,19 @@ func (c *connection) GetConnID() uint32 {
 	return c.connID
 }
 
+func (c *connection) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
 func (c *connection) RemoteAddr() net.Addr {
 	return c.conn.RemoteAddr()
 }
 
+// 获取最后一次收到完整数据包的时间
+func (c *connection) GetLastActiveTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.lastActive))
+}
+
 func (c *connection) SendErrCode(code context.Code) {
 	ctx := globalPool.GetContext()
 	ctx.Result = code
@@ -118,28 +232,165 @@ func (c *connection) SendErrCode(code context.Code) {
 }
 
 func (c *connection) Send(ctx *context.Context, data []byte) error {
-	if c.closed {
-		return errors.New("Send error: connection closed when send message.")
+	if c.isClosed() {
+		return ErrConnClosed
 	}
 
 	// set data in context
 	ctx.Data = data
 
-	msg := globalPool.GetMessage()
-	msg.Reset(ctx)
-	defer globalPool.PutMessage(msg)
-
 	// 封包处理
-	pack := NewDataPack()
-	binaryMsg, err := pack.Pack(msg)
+	binaryMsg, err := c.packContext(ctx)
 	if err != nil {
 		return fmt.Errorf("Send error: pack failed, %v", err)
 	}
 
 	// 发送数据给客户端
-	c.msgChan <- binaryMsg
+	return c.enqueue(binaryMsg)
+}
 
-	return nil
+// 向客户端主动推送消息。请求的上下文在处理完毕后会被回收，推送不能复用，因此使用独立的上下文
+func (c *connection) Push(serviceID, methodID uint32, msg proto.Message) error {
+	var data []byte
+	if msg != nil {
+		var err error
+		if data, err = proto.Marshal(msg); err != nil {
+			return fmt.Errorf("Push error: marshal failed, %v", err)
+		}
+	}
+
+	ctx := &context.Context{ServiceId: serviceID, MethodId: methodID, Type: context.Type_PUSH}
+	return c.Send(ctx, data)
+}
+
+// 尝试将数据包放入发送队列，队列已满时不等待
+func (c *connection) tryEnqueue(binaryMsg []byte) bool {
+	select {
+	case c.msgChan <- binaryMsg:
+		return true
+	default:
+		return false
+	}
+}
+
+// 将数据包放入发送队列，队列已满时按 sendPolicy 处理
+func (c *connection) enqueue(binaryMsg []byte) error {
+	select {
+	case c.msgChan <- binaryMsg:
+		return nil
+	case <-c.existChan:
+		return ErrConnClosed
+	default:
+	}
+
+	// 发送队列已满
+	switch c.sendPolicy {
+	case SendDropOldest:
+		for {
+			select {
+			case c.msgChan <- binaryMsg:
+				return nil
+			case <-c.existChan:
+				return ErrConnClosed
+			default:
+			}
+
+			// 丢弃最早的消息后重试
+			select {
+			case <-c.msgChan:
+				log.Warnf("connID = %d send queue is full, drop oldest message", c.connID)
+			default:
+			}
+		}
+
+	case SendDropNewest:
+		log.Warnf("connID = %d send queue is full, drop message", c.connID)
+		return ErrSendQueueFull
+
+	case SendDisconnect:
+		log.Warnf("connID = %d send queue is full, disconnect", c.connID)
+		// 直接关闭套接字，阻塞在写操作上的writer立即退出，由reader退出时停止链接
+		c.conn.Close()
+		return ErrSendQueueFull
+
+	default:
+		var timeout <-chan time.Time
+		if c.sendTimeout > 0 {
+			timer := time.NewTimer(c.sendTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case c.msgChan <- binaryMsg:
+			return nil
+		case <-c.existChan:
+			return ErrConnClosed
+		case <-timeout:
+			return ErrSendTimeout
+		}
+	}
+}
+
+// 序列化上下文并封包
+func packContext(packer DataPacker, ctx *context.Context) ([]byte, error) {
+	data, err := proto.Marshal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return packer.Pack(&codec.Frame{Data: data})
+}
+
+// 使用链接协商的压缩算法及会话密钥封包
+func (c *connection) packContext(ctx *context.Context) ([]byte, error) {
+	data, err := proto.Marshal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.packData(data, nil)
+}
+
+// 将序列化后的上下文封包。未加密的链接使用服务器共享的封包模块，相同 flags 的结果相同，
+// cache 不为空时按 flags 缓存封包结果，广播时同一消息只需压缩、封包一次
+func (c *connection) packData(data []byte, cache map[uint8][]byte) ([]byte, error) {
+	flags := uint8(atomic.LoadUint32(&c.compression))
+	if uint32(len(data)) < c.compressThreshold {
+		flags = 0
+	}
+
+	if sealed := c.getSealedPacker(); sealed != nil {
+		// 每个链接的会话密钥不同，无法共享封包结果
+		return sealed.Pack(&codec.Frame{Flags: flags | codec.FlagEncrypted, Data: data})
+	}
+
+	if buf, ok := cache[flags]; ok {
+		return buf, nil
+	}
+	buf, err := c.server.GetDataPacker().Pack(&codec.Frame{Flags: flags, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		cache[flags] = buf
+	}
+	return buf, nil
+}
+
+// 获取绑定了会话密钥的封包、拆包模块，未完成加密握手时为空
+func (c *connection) getSealedPacker() DataPacker {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.sealed
+}
+
+// 获取发送队列中等待写出的消息数
+func (c *connection) SendQueueLen() int {
+	return len(c.msgChan)
+}
+
+// 获取发送队列的容量
+func (c *connection) SendQueueCap() int {
+	return cap(c.msgChan)
 }
 
 // 设置链接属性
@@ -165,62 +416,87 @@ func (c *connection) startReader() {
 	defer func() {
 		log.Debugf("Reader is exit! connID=%d", c.connID)
 		c.Stop()
+		c.closeStreams()
 	}()
 
-	pack := NewDataPack()
+	pack := c.server.GetDataPacker()
+	requireEncryption := c.server.GetOptions().RequireEncryption
 
-	for {
-		headData := make([]byte, pack.GetHeadLen())
+	// 是否已完成加密握手，此后使用绑定了会话密钥的封包、拆包模块
+	encrypted := false
 
-		// 1、读取数据包头部数据
-		_, err := io.ReadFull(c.GetTCPConnection(), headData)
-		if err != nil {
-			log.Warnf("read head data error: %v", err)
-			//c.SendErrCode(context.Code_ERR_GET_HEAD)
-			break
+	for {
+		// 1、读取一个完整的数据包
+		frame, err := pack.Unpack(c.conn)
+		if err == codec.ErrChecksum {
+			// 回执校验和失败，数据包已被完整读出，继续读取下一个数据包
+			log.Warn("Checksum failed.")
+			go c.SendErrCode(context.Code_ERR_CHECKSUM)
+			continue
 		}
-
-		// 2、解析消息头部数据
-		msg := globalPool.GetMessage()
-		err = pack.Unpack(headData, msg)
 		if err != nil {
-			log.Errorf("unpack head data error: %v", err)
-			globalPool.PutMessage(msg)
-			//c.SendErrCode(context.Code_ERR_UNPACK_HEAD)
+			log.Warnf("read data error: %v", err)
 			break
 		}
 
-		if msg.GetLen() > 0 {
-			// msg 有数据
-			// 3、根据dataLen将data读出来
-			data := make([]byte, msg.GetLen())
-			if _, err := io.ReadFull(c.GetTCPConnection(), data); err != nil {
-				log.Errorf("get message data error: %v", err)
-				globalPool.PutMessage(msg)
-				//c.SendErrCode(context.Code_ERR_GET_DATA)
+		if len(frame.Data) > 0 {
+			atomic.StoreInt64(&c.lastActive, time.Now().UnixNano())
+
+			// 加密链接上的明文数据包可能被伪造
+			if encrypted && frame.Flags&codec.FlagEncrypted == 0 {
+				log.Warnf("connID = %d recv plaintext package on encrypted connection", c.connID)
 				break
 			}
 
-			msg.SetData(data)
+			// 2、读取数据完毕, 交给Worker的任务队列
+			req := globalPool.GetRequest()
+			req.SetRequest(c, frame.Data)
 
-			// 4、crc32校验
-			if !msg.ChecksumIEEE() {
-				// 回执校验和失败
-				log.Warn("Checksum failed.")
-				globalPool.PutMessage(msg)
-				go c.SendErrCode(context.Code_ERR_CHECKSUM)
-				continue
+			t := req.GetContext().GetType()
+			if requireEncryption && !encrypted && (t == context.Type_REQUEST || t == context.Type_STREAM_OPEN) {
+				log.Warnf("connID = %d encryption is required", c.connID)
+				globalPool.PutContext(req.GetContext())
+				globalPool.PutRequest(req)
+				break
 			}
 
-			// 5、读取数据完毕, 交给Worker的任务队列
-			req := globalPool.GetRequest()
-			req.SetRequest(c, msg.GetData())
-			c.msgHandler.EntryTaskToWorkerPool(req)
+			// 心跳、握手消息由reader直接处理，不进入工作池
+			switch t {
+			case context.Type_HANDSHAKE:
+				sealed, ok := c.handshake(req.GetContext().GetData(), encrypted)
+				globalPool.PutContext(req.GetContext())
+				globalPool.PutRequest(req)
+				if !ok {
+					return
+				}
+				if sealed != nil {
+					pack, encrypted = sealed, true
+				}
+			case context.Type_STREAM_OPEN:
+				// 流的整个生命周期都需要打开流的消息，不回收上下文
+				c.openStream(req.GetContext())
+				globalPool.PutRequest(req)
+			case context.Type_STREAM_DATA, context.Type_STREAM_END, context.Type_STREAM_RESET, context.Type_STREAM_WINDOW:
+				c.dispatchStream(req.GetContext())
+				globalPool.PutContext(req.GetContext())
+				globalPool.PutRequest(req)
+			case context.Type_CANCEL:
+				c.calls.cancel(req.GetSeq())
+				globalPool.PutContext(req.GetContext())
+				globalPool.PutRequest(req)
+			case context.Type_PING:
+				c.sendHeartbeat(context.Type_PONG)
+				fallthrough
+			case context.Type_PONG:
+				globalPool.PutContext(req.GetContext())
+				globalPool.PutRequest(req)
+			default:
+				c.bindContext(req)
+				c.msgHandler.EntryTaskToWorkerPool(req)
+			}
 
 			// 未开启工作池，直接一个协程进行处理
 			// go c.msgHandler.HandleRequest(req)
-
-			globalPool.PutMessage(msg)
 		}
 	}
 }
@@ -232,6 +508,7 @@ func (c *connection) startWriter() {
 	log.Debugf("Writer connID=%d goroutine is running", c.connID)
 	defer func() {
 		log.Debugf("Writer is exit! connID=%d", c.connID)
+		close(c.writerDone)
 	}()
 
 	// 阻塞等待channel的消息，进行写给客户端
@@ -239,12 +516,34 @@ func (c *connection) startWriter() {
 		select {
 		case data := <-c.msgChan:
 			// 有写数据
+			if c.writeTimeout > 0 {
+				c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+			}
 			if _, err := c.conn.Write(data); err != nil {
 				log.Warnf("Send data error: %v", err)
+				// 写失败，链接已不可用。关闭套接字，由reader退出时停止链接
+				c.conn.Close()
 				return
 			}
 		case <-c.existChan:
-			// 表示reader已经退出，此时writer同时结束
+			// 链接停止，将尚未写出的消息写出后结束
+			c.flush()
+			return
+		}
+	}
+}
+
+// 将已提交到 msgChan 的消息全部写出
+func (c *connection) flush() {
+	c.conn.SetWriteDeadline(time.Now().Add(flushTimeout))
+	for {
+		select {
+		case data := <-c.msgChan:
+			if _, err := c.conn.Write(data); err != nil {
+				log.Warnf("Flush data error: %v", err)
+				return
+			}
+		default:
 			return
 		}
 	}
@@ -0,0 +1,127 @@
+package transport
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/codec"
+	"github.com/treeforest/gos/transport/context"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+// 从 conn 中读取一个数据包并解析其上下文
+func readContext(t *testing.T, conn net.Conn) *context.Context {
+	conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+
+	f, err := codec.NewLegacyPacker(0).Unpack(conn)
+	if err != nil {
+		t.Fatalf("unpack error: %v", err)
+	}
+
+	ctx := new(context.Context)
+	if err := proto.Unmarshal(f.Data, ctx); err != nil {
+		t.Fatalf("unmarshal context error: %v", err)
+	}
+	return ctx
+}
+
+// 从 conn 中读取 n 个数据包，返回各数据包携带的数据
+func readFrames(t *testing.T, conn net.Conn, n int) []string {
+	var frames []string
+	for i := 0; i < n; i++ {
+		frames = append(frames, string(readContext(t, conn).Data))
+	}
+	return frames
+}
+
+// 测试发送队列满时的各处理策略
+func TestSendPolicy(t *testing.T) {
+	const queueSize = 2
+
+	// 建立一个客户端不读取数据的链接，writer 阻塞在第一条消息上，随后填满发送队列
+	newSlowConn := func(policy SendPolicy) (Connection, net.Conn) {
+		s := NewServer(WithSendQueueSize(queueSize), WithSendPolicy(policy, time.Millisecond*50))
+		serverConn, clientConn := net.Pipe()
+		c := NewConnection(s, serverConn, 1, s.(*server).msgHandler)
+		c.Start()
+
+		if err := c.Send(new(context.Context), []byte("0")); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for c.SendQueueLen() != 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		for i := 1; i <= queueSize; i++ {
+			if err := c.Send(new(context.Context), []byte{byte('0' + i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if c.SendQueueLen() != queueSize || c.SendQueueCap() != queueSize {
+			t.Fatalf("send queue len = %d cap = %d, want %d", c.SendQueueLen(), c.SendQueueCap(), queueSize)
+		}
+		return c, clientConn
+	}
+
+	t.Run("Block", func(t *testing.T) {
+		c, clientConn := newSlowConn(SendBlock)
+		defer clientConn.Close()
+
+		start := time.Now()
+		if err := c.Send(new(context.Context), []byte("3")); err != ErrSendTimeout {
+			t.Errorf("send error = %v, want %v", err, ErrSendTimeout)
+		}
+		if d := time.Since(start); d < time.Millisecond*50 {
+			t.Errorf("send returned after %v, want blocking for the timeout", d)
+		}
+
+		// 客户端开始读取后，消息按顺序写出
+		go func() { c.Send(new(context.Context), []byte("3")) }()
+		if frames := readFrames(t, clientConn, 4); frames[0] != "0" || frames[3] != "3" {
+			t.Errorf("recv frames = %v", frames)
+		}
+		c.Stop()
+	})
+
+	t.Run("DropNewest", func(t *testing.T) {
+		c, clientConn := newSlowConn(SendDropNewest)
+		defer clientConn.Close()
+
+		if err := c.Send(new(context.Context), []byte("3")); err != ErrSendQueueFull {
+			t.Errorf("send error = %v, want %v", err, ErrSendQueueFull)
+		}
+		if frames := readFrames(t, clientConn, 3); frames[2] != "2" {
+			t.Errorf("recv frames = %v", frames)
+		}
+		c.Stop()
+	})
+
+	t.Run("DropOldest", func(t *testing.T) {
+		c, clientConn := newSlowConn(SendDropOldest)
+		defer clientConn.Close()
+
+		if err := c.Send(new(context.Context), []byte("3")); err != nil {
+			t.Errorf("send error = %v", err)
+		}
+		if frames := readFrames(t, clientConn, 3); frames[0] != "0" || frames[1] != "2" || frames[2] != "3" {
+			t.Errorf("recv frames = %v", frames)
+		}
+		c.Stop()
+	})
+
+	t.Run("Disconnect", func(t *testing.T) {
+		c, clientConn := newSlowConn(SendDisconnect)
+		defer clientConn.Close()
+
+		if err := c.Send(new(context.Context), []byte("3")); err != ErrSendQueueFull {
+			t.Errorf("send error = %v, want %v", err, ErrSendQueueFull)
+		}
+
+		clientConn.SetReadDeadline(time.Now().Add(time.Second * 3))
+		if _, err := io.Copy(ioutil.Discard, clientConn); err != nil {
+			t.Errorf("slow connection should be closed, read error: %v", err)
+		}
+	})
+}
@@ -1,29 +1,42 @@
 package transport
 
 import (
+	gocontext "context"
 	"errors"
 	"github.com/treeforest/logger"
+	"net"
 	"sync"
 )
 
 type connManager struct {
 	// 管理连接的map集合 map[uint32]Connection
 	connMap sync.Map
+
+	// 每个IP的连接数
+	ipLock  sync.Mutex
+	ipCount map[string]uint32
 }
 
 func NewConnManager() ConnManager {
-	return &connManager{}
+	return &connManager{ipCount: make(map[string]uint32)}
 }
 
 // 添加链接
 func (m *connManager) Add(conn Connection) {
 	m.connMap.Store(conn.GetConnID(), conn)
+
+	m.ipLock.Lock()
+	m.ipCount[remoteIP(conn.RemoteAddr())]++
+	m.ipLock.Unlock()
 	log.Debugf("connID = %d add to ConnManager success: conn num = %d", conn.GetConnID(), m.Len())
 }
 
 // 删除链接
 func (m *connManager) Remove(conn Connection) {
-	m.connMap.Delete(conn.GetConnID())
+	if _, ok := m.connMap.LoadAndDelete(conn.GetConnID()); !ok {
+		return
+	}
+	m.decIP(conn)
 	log.Debugf("connID = %d remove to ConnManager success: conn num = %d", conn.GetConnID(), m.Len())
 }
 
@@ -35,6 +48,26 @@ func (m *connManager) Get(connID uint32) (Connection, error) {
 	return nil, errors.New("connection not FOUND!")
 }
 
+// 来自 ip 的连接数
+func (m *connManager) LenByIP(ip string) uint32 {
+	m.ipLock.Lock()
+	defer m.ipLock.Unlock()
+	return m.ipCount[ip]
+}
+
+func (m *connManager) decIP(conn Connection) {
+	ip := remoteIP(conn.RemoteAddr())
+
+	m.ipLock.Lock()
+	defer m.ipLock.Unlock()
+
+	if m.ipCount[ip] <= 1 {
+		delete(m.ipCount, ip)
+	} else {
+		m.ipCount[ip]--
+	}
+}
+
 // 当前连接总数
 func (m *connManager) Len() uint32 {
 	var nLen uint32 = 0
@@ -47,14 +80,70 @@ func (m *connManager) Len() uint32 {
 
 // 清除并终止所有连接
 func (m *connManager) ClearAllConn() {
+	m.ClearAllConnContext(gocontext.Background())
+}
+
+/*
+	并发终止所有连接，每个连接写出已提交的消息后关闭
+	ctx 结束时强制关闭尚未终止的连接的套接字，不再等待写出，并返回 ctx.Err()
+*/
+func (m *connManager) ClearAllConnContext(ctx gocontext.Context) error {
+	// 尚未终止的链接 map[connID]Connection
+	var remaining sync.Map
+	var wg sync.WaitGroup
 	m.connMap.Range(func(key, value interface{}) bool {
-		// 主动停止链接
 		conn := value.(Connection)
-		conn.Stop()
+		remaining.Store(key, conn)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		// 删除元素
-		m.connMap.Delete(key)
+			// 主动停止链接
+			conn.Stop()
+			remaining.Delete(key)
 
+			// 删除元素
+			if _, ok := m.connMap.LoadAndDelete(key); ok {
+				m.decIP(conn)
+			}
+		}()
 		return true
 	})
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// 关闭套接字后 writer 的写入立即失败，Stop 随之完成
+	remaining.Range(func(key, value interface{}) bool {
+		log.Warnf("connID = %d force closed", key)
+		value.(Connection).GetConn().Close()
+		return true
+	})
+	return ctx.Err()
+}
+
+// 遍历所有链接，f 返回false时停止遍历
+func (m *connManager) Range(f func(conn Connection) bool) {
+	m.connMap.Range(func(key, value interface{}) bool {
+		return f(value.(Connection))
+	})
+}
+
+// 获取远程地址的IP，无法解析时返回地址本身
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
 }
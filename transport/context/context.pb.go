@@ -1,15 +1,15 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.24.0-devel
+// 	protoc-gen-go v1.30.0
 // 	protoc        v3.12.3
 // source: context.proto
 
 package context
 
 import (
-	proto "github.com/golang/protobuf/proto"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	anypb "google.golang.org/protobuf/types/known/anypb"
 	reflect "reflect"
 	sync "sync"
 )
@@ -21,42 +21,78 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
-// This is a compile-time assertion that a sufficiently up-to-date version
-// of the legacy proto package is being used.
-const _ = proto.ProtoPackageIsVersion4
-
-// 网络层错误代码
+// 框架错误代码
+// 错误码 [0, 1000) 保留给框架，即本枚举；业务错误码从 1000 开始，通过 Status.code 返回
 type Code int32
 
 const (
-	Code_SUCCESS          Code = 0
-	Code_ERR_CHECKSUM     Code = 1 // 校验失败
-	Code_ERR_GET_HEAD     Code = 2 // 获取 head 失败
-	Code_ERR_GET_DATALEN  Code = 3 // 获取 dataLen 失败
-	Code_ERR_GET_CHECKSUM Code = 4 // 获取 checkSum 失败
-	Code_ERR_GET_DATA     Code = 5 // 获取 data 失败
-	Code_ERR_UNPACK_HEAD  Code = 6 // 解包失败
+	Code_SUCCESS               Code = 0
+	Code_ERR_CHECKSUM          Code = 1  // 校验失败
+	Code_ERR_GET_HEAD          Code = 2  // 获取 head 失败
+	Code_ERR_GET_DATALEN       Code = 3  // 获取 dataLen 失败
+	Code_ERR_GET_CHECKSUM      Code = 4  // 获取 checkSum 失败
+	Code_ERR_GET_DATA          Code = 5  // 获取 data 失败
+	Code_ERR_UNPACK_HEAD       Code = 6  // 解包失败
+	Code_ERR_SERVICE_NOT_FOUND Code = 7  // 服务不存在
+	Code_ERR_METHOD_NOT_FOUND  Code = 8  // 方法不存在
+	Code_ERR_UNMARSHAL_REQUEST Code = 9  // 请求数据解析失败
+	Code_ERR_HANDLE            Code = 10 // 业务处理失败
+	Code_ERR_INTERNAL          Code = 11 // 服务器内部错误
+	Code_ERR_SERVER_FULL       Code = 12 // 超出服务器最大连接数
+	Code_ERR_TOO_MANY_CONN     Code = 13 // 超出单个IP的最大连接数
+	Code_ERR_HANDSHAKE         Code = 14 // 握手失败
+	Code_ERR_INVALID_SESSION   Code = 15 // session 不存在或已过期
+	Code_ERR_DEADLINE_EXCEEDED Code = 16 // 请求超时
+	Code_ERR_CANCELED          Code = 17 // 请求被取消
+	Code_ERR_STREAM_REFUSED    Code = 18 // 超出单个链接的最大流数
+	Code_ERR_FLOW_CONTROL      Code = 19 // 对方发送的数据超出了流量控制窗口
 )
 
 // Enum value maps for Code.
 var (
 	Code_name = map[int32]string{
-		0: "SUCCESS",
-		1: "ERR_CHECKSUM",
-		2: "ERR_GET_HEAD",
-		3: "ERR_GET_DATALEN",
-		4: "ERR_GET_CHECKSUM",
-		5: "ERR_GET_DATA",
-		6: "ERR_UNPACK_HEAD",
+		0:  "SUCCESS",
+		1:  "ERR_CHECKSUM",
+		2:  "ERR_GET_HEAD",
+		3:  "ERR_GET_DATALEN",
+		4:  "ERR_GET_CHECKSUM",
+		5:  "ERR_GET_DATA",
+		6:  "ERR_UNPACK_HEAD",
+		7:  "ERR_SERVICE_NOT_FOUND",
+		8:  "ERR_METHOD_NOT_FOUND",
+		9:  "ERR_UNMARSHAL_REQUEST",
+		10: "ERR_HANDLE",
+		11: "ERR_INTERNAL",
+		12: "ERR_SERVER_FULL",
+		13: "ERR_TOO_MANY_CONN",
+		14: "ERR_HANDSHAKE",
+		15: "ERR_INVALID_SESSION",
+		16: "ERR_DEADLINE_EXCEEDED",
+		17: "ERR_CANCELED",
+		18: "ERR_STREAM_REFUSED",
+		19: "ERR_FLOW_CONTROL",
 	}
 	Code_value = map[string]int32{
-		"SUCCESS":          0,
-		"ERR_CHECKSUM":     1,
-		"ERR_GET_HEAD":     2,
-		"ERR_GET_DATALEN":  3,
-		"ERR_GET_CHECKSUM": 4,
-		"ERR_GET_DATA":     5,
-		"ERR_UNPACK_HEAD":  6,
+		"SUCCESS":               0,
+		"ERR_CHECKSUM":          1,
+		"ERR_GET_HEAD":          2,
+		"ERR_GET_DATALEN":       3,
+		"ERR_GET_CHECKSUM":      4,
+		"ERR_GET_DATA":          5,
+		"ERR_UNPACK_HEAD":       6,
+		"ERR_SERVICE_NOT_FOUND": 7,
+		"ERR_METHOD_NOT_FOUND":  8,
+		"ERR_UNMARSHAL_REQUEST": 9,
+		"ERR_HANDLE":            10,
+		"ERR_INTERNAL":          11,
+		"ERR_SERVER_FULL":       12,
+		"ERR_TOO_MANY_CONN":     13,
+		"ERR_HANDSHAKE":         14,
+		"ERR_INVALID_SESSION":   15,
+		"ERR_DEADLINE_EXCEEDED": 16,
+		"ERR_CANCELED":          17,
+		"ERR_STREAM_REFUSED":    18,
+		"ERR_FLOW_CONTROL":      19,
 	}
 )
 
@@ -87,17 +123,150 @@ func (Code) EnumDescriptor() ([]byte, []int) {
 	return file_context_proto_rawDescGZIP(), []int{0}
 }
 
+// 请求优先级，负载较高时优先处理高优先级的请求
+type Priority int32
+
+const (
+	Priority_NORMAL Priority = 0 // 普通
+	Priority_HIGH   Priority = 1 // 高，如登录、心跳、支付
+	Priority_LOW    Priority = 2 // 低，如聊天、数据上报
+)
+
+// Enum value maps for Priority.
+var (
+	Priority_name = map[int32]string{
+		0: "NORMAL",
+		1: "HIGH",
+		2: "LOW",
+	}
+	Priority_value = map[string]int32{
+		"NORMAL": 0,
+		"HIGH":   1,
+		"LOW":    2,
+	}
+)
+
+func (x Priority) Enum() *Priority {
+	p := new(Priority)
+	*p = x
+	return p
+}
+
+func (x Priority) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Priority) Descriptor() protoreflect.EnumDescriptor {
+	return file_context_proto_enumTypes[1].Descriptor()
+}
+
+func (Priority) Type() protoreflect.EnumType {
+	return &file_context_proto_enumTypes[1]
+}
+
+func (x Priority) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Priority.Descriptor instead.
+func (Priority) EnumDescriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{1}
+}
+
+// 消息类型
+type Type int32
+
+const (
+	Type_REQUEST       Type = 0  // 请求/响应
+	Type_PING          Type = 1  // 心跳请求，收到后回复 PONG
+	Type_PONG          Type = 2  // 心跳响应
+	Type_HANDSHAKE     Type = 3  // 握手，data 为序列化的 Handshake
+	Type_PUSH          Type = 4  // 服务器主动推送的消息，不对应任何请求
+	Type_CANCEL        Type = 5  // 取消请求，seq 为要取消的请求序列号
+	Type_STREAM_OPEN   Type = 6  // 客户端打开流，window 为客户端的接收窗口
+	Type_STREAM_DATA   Type = 7  // 流上的一条消息
+	Type_STREAM_END    Type = 8  // 客户端结束发送(半关闭)；服务端结束整个流，携带返回码与 trailer
+	Type_STREAM_RESET  Type = 9  // 中止流，result 为中止的原因
+	Type_STREAM_WINDOW Type = 10 // 增加对方的发送窗口，服务端接受流时首先发送该消息告知接收窗口
+)
+
+// Enum value maps for Type.
+var (
+	Type_name = map[int32]string{
+		0:  "REQUEST",
+		1:  "PING",
+		2:  "PONG",
+		3:  "HANDSHAKE",
+		4:  "PUSH",
+		5:  "CANCEL",
+		6:  "STREAM_OPEN",
+		7:  "STREAM_DATA",
+		8:  "STREAM_END",
+		9:  "STREAM_RESET",
+		10: "STREAM_WINDOW",
+	}
+	Type_value = map[string]int32{
+		"REQUEST":       0,
+		"PING":          1,
+		"PONG":          2,
+		"HANDSHAKE":     3,
+		"PUSH":          4,
+		"CANCEL":        5,
+		"STREAM_OPEN":   6,
+		"STREAM_DATA":   7,
+		"STREAM_END":    8,
+		"STREAM_RESET":  9,
+		"STREAM_WINDOW": 10,
+	}
+)
+
+func (x Type) Enum() *Type {
+	p := new(Type)
+	*p = x
+	return p
+}
+
+func (x Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_context_proto_enumTypes[2].Descriptor()
+}
+
+func (Type) Type() protoreflect.EnumType {
+	return &file_context_proto_enumTypes[2]
+}
+
+func (x Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Type.Descriptor instead.
+func (Type) EnumDescriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{2}
+}
+
 // 服务传输上下文
 type Context struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Result    Code   `protobuf:"varint,1,opt,name=result,proto3,enum=Code" json:"result,omitempty"` // 返回码
-	Session   uint32 `protobuf:"varint,2,opt,name=session,proto3" json:"session,omitempty"`         // 登录后会获得session
-	ServiceId uint32 `protobuf:"varint,3,opt,name=serviceId,proto3" json:"serviceId,omitempty"`     // 服务id
-	MethodId  uint32 `protobuf:"varint,4,opt,name=methodId,proto3" json:"methodId,omitempty"`       // 方法id
-	Data      []byte `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`                // 传输的数据
+	Result    Code              `protobuf:"varint,1,opt,name=result,proto3,enum=Code" json:"result,omitempty"`                                                                                   // 返回码
+	Session   string            `protobuf:"bytes,15,opt,name=session,proto3" json:"session,omitempty"`                                                                                           // 登录后获得的会话令牌，不可猜测的随机字符串
+	ServiceId uint32            `protobuf:"varint,3,opt,name=serviceId,proto3" json:"serviceId,omitempty"`                                                                                       // 服务id
+	MethodId  uint32            `protobuf:"varint,4,opt,name=methodId,proto3" json:"methodId,omitempty"`                                                                                         // 方法id
+	Data      []byte            `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`                                                                                                  // 传输的数据
+	Priority  Priority          `protobuf:"varint,6,opt,name=priority,proto3,enum=Priority" json:"priority,omitempty"`                                                                           // 请求优先级，仅在服务端开启 WithClientPriority 且未为服务/方法设置优先级时生效
+	Type      Type              `protobuf:"varint,7,opt,name=type,proto3,enum=Type" json:"type,omitempty"`                                                                                       // 消息类型
+	Seq       uint32            `protobuf:"varint,8,opt,name=seq,proto3" json:"seq,omitempty"`                                                                                                   // 请求序列号，服务端回复时原样带回，用于匹配请求与响应；为0时不关联
+	Status    *Status           `protobuf:"bytes,9,opt,name=status,proto3" json:"status,omitempty"`                                                                                              // 请求失败时的错误详情，业务错误时 result 为 ERR_HANDLE
+	Metadata  map[string]string `protobuf:"bytes,10,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // 请求的元数据，如语言、客户端版本、鉴权token、链路id、设备id
+	Trailer   map[string]string `protobuf:"bytes,11,rep,name=trailer,proto3" json:"trailer,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`   // 响应的元数据，由处理函数设置
+	Timeout   uint32            `protobuf:"varint,12,opt,name=timeout,proto3" json:"timeout,omitempty"`                                                                                          // 请求的超时时间(毫秒)，服务端自收到请求起计时，0 表示不超时
+	StreamId  uint32            `protobuf:"varint,13,opt,name=streamId,proto3" json:"streamId,omitempty"`                                                                                        // 流id，由客户端分配，非0
+	Window    uint32            `protobuf:"varint,14,opt,name=window,proto3" json:"window,omitempty"`                                                                                            // 流量控制窗口(字节)
 }
 
 func (x *Context) Reset() {
@@ -139,11 +308,11 @@ func (x *Context) GetResult() Code {
 	return Code_SUCCESS
 }
 
-func (x *Context) GetSession() uint32 {
+func (x *Context) GetSession() string {
 	if x != nil {
 		return x.Session
 	}
-	return 0
+	return ""
 }
 
 func (x *Context) GetServiceId() uint32 {
@@ -167,30 +336,294 @@ func (x *Context) GetData() []byte {
 	return nil
 }
 
+func (x *Context) GetPriority() Priority {
+	if x != nil {
+		return x.Priority
+	}
+	return Priority_NORMAL
+}
+
+func (x *Context) GetType() Type {
+	if x != nil {
+		return x.Type
+	}
+	return Type_REQUEST
+}
+
+func (x *Context) GetSeq() uint32 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Context) GetStatus() *Status {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *Context) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Context) GetTrailer() map[string]string {
+	if x != nil {
+		return x.Trailer
+	}
+	return nil
+}
+
+func (x *Context) GetTimeout() uint32 {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
+}
+
+func (x *Context) GetStreamId() uint32 {
+	if x != nil {
+		return x.StreamId
+	}
+	return 0
+}
+
+func (x *Context) GetWindow() uint32 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
+// 结构化的错误信息
+type Status struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Code    uint32       `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`      // 错误码，框架错误与 Code 取值相同，业务错误不小于 1000
+	Message string       `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"` // 错误描述
+	Details []*anypb.Any `protobuf:"bytes,3,rep,name=details,proto3" json:"details,omitempty"` // 附加的错误详情
+}
+
+func (x *Status) Reset() {
+	*x = Status{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_context_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Status) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Status) ProtoMessage() {}
+
+func (x *Status) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Status.ProtoReflect.Descriptor instead.
+func (*Status) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Status) GetCode() uint32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *Status) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Status) GetDetails() []*anypb.Any {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
+// 握手参数，协商链接的压缩算法与会话密钥
+type Handshake struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Compressions []uint32 `protobuf:"varint,1,rep,packed,name=compressions,proto3" json:"compressions,omitempty"` // 客户端按偏好排列的压缩算法，服务端回复时为选定的算法
+	PublicKey    []byte   `protobuf:"bytes,2,opt,name=publicKey,proto3" json:"publicKey,omitempty"`               // 双方的 box 公钥，为空时不加密
+	SessionKey   []byte   `protobuf:"bytes,3,opt,name=sessionKey,proto3" json:"sessionKey,omitempty"`             // 服务端使用 box 加密的 secretbox 会话密钥
+}
+
+func (x *Handshake) Reset() {
+	*x = Handshake{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_context_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Handshake) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Handshake) ProtoMessage() {}
+
+func (x *Handshake) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Handshake.ProtoReflect.Descriptor instead.
+func (*Handshake) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Handshake) GetCompressions() []uint32 {
+	if x != nil {
+		return x.Compressions
+	}
+	return nil
+}
+
+func (x *Handshake) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *Handshake) GetSessionKey() []byte {
+	if x != nil {
+		return x.SessionKey
+	}
+	return nil
+}
+
 var File_context_proto protoreflect.FileDescriptor
 
 var file_context_proto_rawDesc = []byte{
-	0x0a, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
-	0x90, 0x01, 0x0a, 0x07, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x12, 0x1d, 0x0a, 0x06, 0x72,
-	0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x05, 0x2e, 0x43, 0x6f,
-	0x64, 0x65, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x65,
-	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x73, 0x65, 0x73,
-	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x49,
-	0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
-	0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x49, 0x64, 0x18, 0x04,
-	0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x49, 0x64, 0x12, 0x12,
-	0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61,
-	0x74, 0x61, 0x2a, 0x89, 0x01, 0x0a, 0x04, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x53,
-	0x55, 0x43, 0x43, 0x45, 0x53, 0x53, 0x10, 0x00, 0x12, 0x10, 0x0a, 0x0c, 0x45, 0x52, 0x52, 0x5f,
-	0x43, 0x48, 0x45, 0x43, 0x4b, 0x53, 0x55, 0x4d, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x45, 0x52,
-	0x52, 0x5f, 0x47, 0x45, 0x54, 0x5f, 0x48, 0x45, 0x41, 0x44, 0x10, 0x02, 0x12, 0x13, 0x0a, 0x0f,
-	0x45, 0x52, 0x52, 0x5f, 0x47, 0x45, 0x54, 0x5f, 0x44, 0x41, 0x54, 0x41, 0x4c, 0x45, 0x4e, 0x10,
-	0x03, 0x12, 0x14, 0x0a, 0x10, 0x45, 0x52, 0x52, 0x5f, 0x47, 0x45, 0x54, 0x5f, 0x43, 0x48, 0x45,
-	0x43, 0x4b, 0x53, 0x55, 0x4d, 0x10, 0x04, 0x12, 0x10, 0x0a, 0x0c, 0x45, 0x52, 0x52, 0x5f, 0x47,
-	0x45, 0x54, 0x5f, 0x44, 0x41, 0x54, 0x41, 0x10, 0x05, 0x12, 0x13, 0x0a, 0x0f, 0x45, 0x52, 0x52,
-	0x5f, 0x55, 0x4e, 0x50, 0x41, 0x43, 0x4b, 0x5f, 0x48, 0x45, 0x41, 0x44, 0x10, 0x06, 0x42, 0x0b,
-	0x5a, 0x09, 0x2e, 0x3b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x33,
+	0x0a, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a,
+	0x19, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2f, 0x61, 0x6e, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xb7, 0x04, 0x0a, 0x07, 0x43,
+	0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x12, 0x1d, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x05, 0x2e, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x06, 0x72,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x1c, 0x0a, 0x09, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x09, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x12, 0x1a, 0x0a,
+	0x08, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x49, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x08, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74,
+	0x61, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x25, 0x0a,
+	0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x09, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x52, 0x08, 0x70, 0x72, 0x69, 0x6f,
+	0x72, 0x69, 0x74, 0x79, 0x12, 0x19, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x05, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12,
+	0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x73, 0x65,
+	0x71, 0x12, 0x1f, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x07, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x12, 0x32, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x0a,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x2e, 0x4d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x6d, 0x65,
+	0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x2f, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x69, 0x6c, 0x65,
+	0x72, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78,
+	0x74, 0x2e, 0x54, 0x72, 0x61, 0x69, 0x6c, 0x65, 0x72, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07,
+	0x74, 0x72, 0x61, 0x69, 0x6c, 0x65, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f,
+	0x75, 0x74, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75,
+	0x74, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x18, 0x0d, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x12, 0x16, 0x0a,
+	0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x77,
+	0x69, 0x6e, 0x64, 0x6f, 0x77, 0x1a, 0x3b, 0x0a, 0x0d, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
+	0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02,
+	0x38, 0x01, 0x1a, 0x3a, 0x0a, 0x0c, 0x54, 0x72, 0x61, 0x69, 0x6c, 0x65, 0x72, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x4a, 0x04,
+	0x08, 0x02, 0x10, 0x03, 0x22, 0x66, 0x0a, 0x06, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x12,
+	0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x63, 0x6f,
+	0x64, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2e, 0x0a, 0x07,
+	0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x41, 0x6e, 0x79, 0x52, 0x07, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x22, 0x6d, 0x0a, 0x09,
+	0x48, 0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x12, 0x22, 0x0a, 0x0c, 0x63, 0x6f, 0x6d,
+	0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0d, 0x52,
+	0x0c, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x1c, 0x0a,
+	0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x12, 0x1e, 0x0a, 0x0a, 0x73,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x4b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x0a, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x4b, 0x65, 0x79, 0x2a, 0xae, 0x03, 0x0a, 0x04,
+	0x43, 0x6f, 0x64, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x55, 0x43, 0x43, 0x45, 0x53, 0x53, 0x10,
+	0x00, 0x12, 0x10, 0x0a, 0x0c, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x48, 0x45, 0x43, 0x4b, 0x53, 0x55,
+	0x4d, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x45, 0x52, 0x52, 0x5f, 0x47, 0x45, 0x54, 0x5f, 0x48,
+	0x45, 0x41, 0x44, 0x10, 0x02, 0x12, 0x13, 0x0a, 0x0f, 0x45, 0x52, 0x52, 0x5f, 0x47, 0x45, 0x54,
+	0x5f, 0x44, 0x41, 0x54, 0x41, 0x4c, 0x45, 0x4e, 0x10, 0x03, 0x12, 0x14, 0x0a, 0x10, 0x45, 0x52,
+	0x52, 0x5f, 0x47, 0x45, 0x54, 0x5f, 0x43, 0x48, 0x45, 0x43, 0x4b, 0x53, 0x55, 0x4d, 0x10, 0x04,
+	0x12, 0x10, 0x0a, 0x0c, 0x45, 0x52, 0x52, 0x5f, 0x47, 0x45, 0x54, 0x5f, 0x44, 0x41, 0x54, 0x41,
+	0x10, 0x05, 0x12, 0x13, 0x0a, 0x0f, 0x45, 0x52, 0x52, 0x5f, 0x55, 0x4e, 0x50, 0x41, 0x43, 0x4b,
+	0x5f, 0x48, 0x45, 0x41, 0x44, 0x10, 0x06, 0x12, 0x19, 0x0a, 0x15, 0x45, 0x52, 0x52, 0x5f, 0x53,
+	0x45, 0x52, 0x56, 0x49, 0x43, 0x45, 0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x55, 0x4e, 0x44,
+	0x10, 0x07, 0x12, 0x18, 0x0a, 0x14, 0x45, 0x52, 0x52, 0x5f, 0x4d, 0x45, 0x54, 0x48, 0x4f, 0x44,
+	0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x55, 0x4e, 0x44, 0x10, 0x08, 0x12, 0x19, 0x0a, 0x15,
+	0x45, 0x52, 0x52, 0x5f, 0x55, 0x4e, 0x4d, 0x41, 0x52, 0x53, 0x48, 0x41, 0x4c, 0x5f, 0x52, 0x45,
+	0x51, 0x55, 0x45, 0x53, 0x54, 0x10, 0x09, 0x12, 0x0e, 0x0a, 0x0a, 0x45, 0x52, 0x52, 0x5f, 0x48,
+	0x41, 0x4e, 0x44, 0x4c, 0x45, 0x10, 0x0a, 0x12, 0x10, 0x0a, 0x0c, 0x45, 0x52, 0x52, 0x5f, 0x49,
+	0x4e, 0x54, 0x45, 0x52, 0x4e, 0x41, 0x4c, 0x10, 0x0b, 0x12, 0x13, 0x0a, 0x0f, 0x45, 0x52, 0x52,
+	0x5f, 0x53, 0x45, 0x52, 0x56, 0x45, 0x52, 0x5f, 0x46, 0x55, 0x4c, 0x4c, 0x10, 0x0c, 0x12, 0x15,
+	0x0a, 0x11, 0x45, 0x52, 0x52, 0x5f, 0x54, 0x4f, 0x4f, 0x5f, 0x4d, 0x41, 0x4e, 0x59, 0x5f, 0x43,
+	0x4f, 0x4e, 0x4e, 0x10, 0x0d, 0x12, 0x11, 0x0a, 0x0d, 0x45, 0x52, 0x52, 0x5f, 0x48, 0x41, 0x4e,
+	0x44, 0x53, 0x48, 0x41, 0x4b, 0x45, 0x10, 0x0e, 0x12, 0x17, 0x0a, 0x13, 0x45, 0x52, 0x52, 0x5f,
+	0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x53, 0x45, 0x53, 0x53, 0x49, 0x4f, 0x4e, 0x10,
+	0x0f, 0x12, 0x19, 0x0a, 0x15, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x45, 0x41, 0x44, 0x4c, 0x49, 0x4e,
+	0x45, 0x5f, 0x45, 0x58, 0x43, 0x45, 0x45, 0x44, 0x45, 0x44, 0x10, 0x10, 0x12, 0x10, 0x0a, 0x0c,
+	0x45, 0x52, 0x52, 0x5f, 0x43, 0x41, 0x4e, 0x43, 0x45, 0x4c, 0x45, 0x44, 0x10, 0x11, 0x12, 0x16,
+	0x0a, 0x12, 0x45, 0x52, 0x52, 0x5f, 0x53, 0x54, 0x52, 0x45, 0x41, 0x4d, 0x5f, 0x52, 0x45, 0x46,
+	0x55, 0x53, 0x45, 0x44, 0x10, 0x12, 0x12, 0x14, 0x0a, 0x10, 0x45, 0x52, 0x52, 0x5f, 0x46, 0x4c,
+	0x4f, 0x57, 0x5f, 0x43, 0x4f, 0x4e, 0x54, 0x52, 0x4f, 0x4c, 0x10, 0x13, 0x2a, 0x29, 0x0a, 0x08,
+	0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x0a, 0x0a, 0x06, 0x4e, 0x4f, 0x52, 0x4d,
+	0x41, 0x4c, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x48, 0x49, 0x47, 0x48, 0x10, 0x01, 0x12, 0x07,
+	0x0a, 0x03, 0x4c, 0x4f, 0x57, 0x10, 0x02, 0x2a, 0xa3, 0x01, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65,
+	0x12, 0x0b, 0x0a, 0x07, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x10, 0x00, 0x12, 0x08, 0x0a,
+	0x04, 0x50, 0x49, 0x4e, 0x47, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x50, 0x4f, 0x4e, 0x47, 0x10,
+	0x02, 0x12, 0x0d, 0x0a, 0x09, 0x48, 0x41, 0x4e, 0x44, 0x53, 0x48, 0x41, 0x4b, 0x45, 0x10, 0x03,
+	0x12, 0x08, 0x0a, 0x04, 0x50, 0x55, 0x53, 0x48, 0x10, 0x04, 0x12, 0x0a, 0x0a, 0x06, 0x43, 0x41,
+	0x4e, 0x43, 0x45, 0x4c, 0x10, 0x05, 0x12, 0x0f, 0x0a, 0x0b, 0x53, 0x54, 0x52, 0x45, 0x41, 0x4d,
+	0x5f, 0x4f, 0x50, 0x45, 0x4e, 0x10, 0x06, 0x12, 0x0f, 0x0a, 0x0b, 0x53, 0x54, 0x52, 0x45, 0x41,
+	0x4d, 0x5f, 0x44, 0x41, 0x54, 0x41, 0x10, 0x07, 0x12, 0x0e, 0x0a, 0x0a, 0x53, 0x54, 0x52, 0x45,
+	0x41, 0x4d, 0x5f, 0x45, 0x4e, 0x44, 0x10, 0x08, 0x12, 0x10, 0x0a, 0x0c, 0x53, 0x54, 0x52, 0x45,
+	0x41, 0x4d, 0x5f, 0x52, 0x45, 0x53, 0x45, 0x54, 0x10, 0x09, 0x12, 0x11, 0x0a, 0x0d, 0x53, 0x54,
+	0x52, 0x45, 0x41, 0x4d, 0x5f, 0x57, 0x49, 0x4e, 0x44, 0x4f, 0x57, 0x10, 0x0a, 0x42, 0x0b, 0x5a,
+	0x09, 0x2e, 0x3b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
 }
 
 var (
@@ -205,19 +638,32 @@ func file_context_proto_rawDescGZIP() []byte {
 	return file_context_proto_rawDescData
 }
 
-var file_context_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_context_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_context_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_context_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_context_proto_goTypes = []interface{}{
-	(Code)(0),       // 0: Code
-	(*Context)(nil), // 1: Context
+	(Code)(0),         // 0: Code
+	(Priority)(0),     // 1: Priority
+	(Type)(0),         // 2: Type
+	(*Context)(nil),   // 3: Context
+	(*Status)(nil),    // 4: Status
+	(*Handshake)(nil), // 5: Handshake
+	nil,               // 6: Context.MetadataEntry
+	nil,               // 7: Context.TrailerEntry
+	(*anypb.Any)(nil), // 8: google.protobuf.Any
 }
 var file_context_proto_depIdxs = []int32{
 	0, // 0: Context.result:type_name -> Code
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	1, // 1: Context.priority:type_name -> Priority
+	2, // 2: Context.type:type_name -> Type
+	4, // 3: Context.status:type_name -> Status
+	6, // 4: Context.metadata:type_name -> Context.MetadataEntry
+	7, // 5: Context.trailer:type_name -> Context.TrailerEntry
+	8, // 6: Status.details:type_name -> google.protobuf.Any
+	7, // [7:7] is the sub-list for method output_type
+	7, // [7:7] is the sub-list for method input_type
+	7, // [7:7] is the sub-list for extension type_name
+	7, // [7:7] is the sub-list for extension extendee
+	0, // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_context_proto_init() }
@@ -238,14 +684,38 @@ func file_context_proto_init() {
 				return nil
 			}
 		}
+		file_context_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Status); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_context_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Handshake); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_context_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   1,
+			NumEnums:      3,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,30 @@
+package transport
+
+import "hash/fnv"
+
+/*
+	有序分发
+
+	按 ShardFunc 的返回值将请求分配到固定worker的消息队列，
+	返回值相同的请求由同一个worker按到达顺序依次处理，不同的返回值之间仍然并行。
+
+	顺序只在 (shard, 优先级) 内保证：worker 优先处理高优先级队列，同一链接先到达的低优先级请求
+	可能晚于后到达的高优先级请求处理。优先级由服务端按服务/方法设置，同一方法的请求总是有序的；
+	需要跨方法保持顺序的请求不应设置不同的优先级
+*/
+type ShardFunc func(req Request) uint64
+
+// 按链接分发，同一链接的请求先进先出
+func ShardByConn(req Request) uint64 {
+	return uint64(req.GetConnection().GetConnID())
+}
+
+// 按 session 分发，同一 session 的请求先进先出；未登录(session 为空)时按链接分发
+func ShardBySession(req Request) uint64 {
+	if session := req.GetSession(); session != "" {
+		h := fnv.New64a()
+		h.Write([]byte(session))
+		return h.Sum64()
+	}
+	return ShardByConn(req)
+}
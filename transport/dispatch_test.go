@@ -0,0 +1,103 @@
+package transport
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试有序分发：同一链接的请求按到达顺序处理
+func TestOrderedDispatch(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		handled = make(map[uint32][]string)
+	)
+
+	s := NewServer(WithAddress("127.0.0.1", 0), WithWorkerPoolSize(4),
+		WithMaxWorkerTaskLen(16), WithOrderedDispatch(ShardByConn))
+	s.RegisterHandler(7, 1, func(req Request, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+		// 随机的处理耗时，共享队列下会导致乱序
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+
+		lock.Lock()
+		connID := req.GetConnection().GetConnID()
+		handled[connID] = append(handled[connID], in.Value)
+		lock.Unlock()
+		return in, nil
+	})
+	s.Start()
+	defer s.Stop()
+
+	const clients, count = 3, 30
+
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		c := client.NewClient()
+		c.Dial(s.Addr().String())
+
+		wg.Add(1)
+		go func(c client.Client) {
+			defer wg.Done()
+			for j := 0; j < count; j++ {
+				data, _ := proto.Marshal(&wrappers.StringValue{Value: fmt.Sprint(j)})
+				c.Send(7, 1, data)
+			}
+			for j := 0; j < count; j++ {
+				if recvTimeout(c, time.Second*3) == nil {
+					t.Errorf("recv message %d timeout", j)
+					return
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(handled) != clients {
+		t.Fatalf("handled %d connections, want %d", len(handled), clients)
+	}
+	for connID, values := range handled {
+		for i, v := range values {
+			if v != fmt.Sprint(i) {
+				t.Fatalf("connID = %d handled order = %v", connID, values)
+			}
+		}
+	}
+}
+
+// 测试有序分发的顺序只在 (shard, 优先级) 内保证：同一 shard 的高优先级请求先于先到达的低优先级请求处理
+func TestOrderedDispatchPriority(t *testing.T) {
+	h := NewOrderedMessageHandler(1, 16, func(Request) uint64 { return 0 }).(*messageHandle)
+	h.SetMethodPriority(1, 1, context.Priority_HIGH)
+	h.SetMethodPriority(1, 2, context.Priority_LOW)
+
+	// methodID 2、1、2、1 依次到达，data 记录到达顺序
+	for i, methodID := range []uint32{2, 1, 2, 1} {
+		req := newPriorityRequest(1, methodID, context.Priority_NORMAL)
+		req.ctx.Data = []byte(fmt.Sprint(i))
+		h.EntryTaskToWorkerPool(req)
+	}
+	h.taskQueues[0].close()
+
+	var order []string
+	r := &taskReceiver{lanes: h.taskQueues[0]}
+	for {
+		req, _, ok := r.receive()
+		if !ok {
+			break
+		}
+		order = append(order, string(req.GetContext().GetData()))
+	}
+
+	if got, want := fmt.Sprint(order), "[1 3 0 2]"; got != want {
+		t.Errorf("handled order = %s, want %s", got, want)
+	}
+}
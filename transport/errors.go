@@ -0,0 +1,31 @@
+package transport
+
+import (
+	"errors"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/status"
+)
+
+var (
+	// 链接已关闭
+	ErrConnClosed = errors.New("connection closed")
+
+	// 发送队列已满，消息被丢弃
+	ErrSendQueueFull = errors.New("send queue is full")
+
+	// 发送队列已满，等待超时
+	ErrSendTimeout = errors.New("send queue wait timeout")
+)
+
+// 创建携带框架错误码的错误，处理函数返回该错误时，将错误码回复给客户端。业务错误使用 status.Error 创建
+func NewError(code context.Code, msg string) error {
+	return status.FromCode(code, msg).Err()
+}
+
+// 获取错误对应的框架错误码，业务错误及未携带错误信息的错误视为业务处理失败
+func ErrorCode(err error) context.Code {
+	if err == nil {
+		return context.Code_SUCCESS
+	}
+	return status.Convert(err).ContextCode()
+}
@@ -0,0 +1,178 @@
+package transport
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/logger"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+	分组管理模块
+	用于聊天室、公会、匹配大厅等场景，链接断开时自动退出所有分组
+*/
+type groupManager struct {
+	lock sync.RWMutex
+
+	// 每个分组的成员 map[group]map[connID]Connection
+	groups map[string]map[uint32]Connection
+
+	// 每个链接加入的分组 map[connID]map[group]struct{}
+	connGroups map[uint32]map[string]struct{}
+}
+
+func NewGroupManager() GroupManager {
+	return &groupManager{
+		groups:     make(map[string]map[uint32]Connection),
+		connGroups: make(map[uint32]map[string]struct{}),
+	}
+}
+
+// 将链接加入分组，已关闭的链接不会被加入
+func (m *groupManager) Join(group string, conn Connection) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// 在锁内检查链接状态：链接关闭时先设置状态再调用 LeaveAll，二者不会交错
+	if c, ok := conn.(*connection); ok && c.isClosed() {
+		return
+	}
+
+	members, ok := m.groups[group]
+	if !ok {
+		members = make(map[uint32]Connection)
+		m.groups[group] = members
+	}
+	members[conn.GetConnID()] = conn
+
+	groups, ok := m.connGroups[conn.GetConnID()]
+	if !ok {
+		groups = make(map[string]struct{})
+		m.connGroups[conn.GetConnID()] = groups
+	}
+	groups[group] = struct{}{}
+}
+
+// 将链接移出分组，分组为空时删除分组
+func (m *groupManager) Leave(group string, conn Connection) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.leave(group, conn.GetConnID())
+}
+
+// 将链接移出其加入的所有分组
+func (m *groupManager) LeaveAll(conn Connection) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for group := range m.connGroups[conn.GetConnID()] {
+		m.leave(group, conn.GetConnID())
+	}
+}
+
+func (m *groupManager) leave(group string, connID uint32) {
+	if members, ok := m.groups[group]; ok {
+		delete(members, connID)
+		if len(members) == 0 {
+			delete(m.groups, group)
+		}
+	}
+	if groups, ok := m.connGroups[connID]; ok {
+		delete(groups, group)
+		if len(groups) == 0 {
+			delete(m.connGroups, connID)
+		}
+	}
+}
+
+// 获取分组的所有成员
+func (m *groupManager) Members(group string) []Connection {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	members := make([]Connection, 0, len(m.groups[group]))
+	for _, conn := range m.groups[group] {
+		members = append(members, conn)
+	}
+	return members
+}
+
+// 获取分组的成员数
+func (m *groupManager) Len(group string) int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return len(m.groups[group])
+}
+
+// 获取链接加入的所有分组
+func (m *groupManager) Groups(conn Connection) []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	groups := make([]string, 0, len(m.connGroups[conn.GetConnID()]))
+	for group := range m.connGroups[conn.GetConnID()] {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// 向分组的所有成员广播消息，返回消息成功放入发送队列的链接数
+func (m *groupManager) Broadcast(group string, serviceID, methodID uint32, data []byte) int {
+	return broadcast(m.Members(group), serviceID, methodID, data)
+}
+
+/*
+	向 conns 广播消息，客户端以推送(PUSH)的形式接收
+	消息只序列化一次，未加密的链接共享压缩、封包的结果。发送队列未满的链接直接入队，
+	已满的链接按各自的发送策略处理，其中 SendBlock 策略的链接并发等待，慢链接不会拖慢其它链接。
+	返回消息成功放入发送队列的链接数
+*/
+func broadcast(conns []Connection, serviceID, methodID uint32, data []byte) int {
+	ctx := &context.Context{ServiceId: serviceID, MethodId: methodID, Type: context.Type_PUSH, Data: data}
+	raw, err := proto.Marshal(ctx)
+	if err != nil {
+		log.Errorf("broadcast serviceID = %d methodID = %d marshal error: %v", serviceID, methodID, err)
+		return 0
+	}
+
+	var (
+		sent  int64
+		wg    sync.WaitGroup
+		cache = make(map[uint8][]byte)
+	)
+	for _, conn := range conns {
+		c, ok := conn.(*connection)
+		if !ok {
+			// 自定义的链接实现
+			if conn.Send(&context.Context{ServiceId: serviceID, MethodId: methodID, Type: context.Type_PUSH}, data) == nil {
+				atomic.AddInt64(&sent, 1)
+			}
+			continue
+		}
+		if c.isClosed() {
+			continue
+		}
+
+		buf, err := c.packData(raw, cache)
+		if err != nil {
+			log.Warnf("broadcast to connID = %d pack error: %v", c.connID, err)
+			continue
+		}
+		if c.tryEnqueue(buf) {
+			atomic.AddInt64(&sent, 1)
+			continue
+		}
+
+		wg.Add(1)
+		go func(c *connection, buf []byte) {
+			defer wg.Done()
+			if c.enqueue(buf) == nil {
+				atomic.AddInt64(&sent, 1)
+			}
+		}(c, buf)
+	}
+	wg.Wait()
+
+	return int(sent)
+}
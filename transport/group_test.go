@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// 测试分组的加入、退出与广播
+func TestGroupBroadcast(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	s.Start()
+	defer s.Stop()
+
+	var clients []net.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("tcp", s.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		clients = append(clients, conn)
+	}
+	waitConnNum(t, s, 3)
+
+	// 前两个链接加入分组
+	var conns []Connection
+	s.GetConnManager().Range(func(conn Connection) bool {
+		conns = append(conns, conn)
+		return true
+	})
+	gm := s.GetGroupManager()
+	for _, conn := range conns {
+		if conn.RemoteAddr().String() != clients[2].LocalAddr().String() {
+			gm.Join("room", conn)
+		}
+	}
+	if n := gm.Len("room"); n != 2 {
+		t.Fatalf("group len = %d, want 2", n)
+	}
+
+	if n := gm.Broadcast("room", 1, 2, []byte("hello room")); n != 2 {
+		t.Errorf("broadcast sent = %d, want 2", n)
+	}
+	for _, conn := range clients[:2] {
+		if ctx := readContext(t, conn); string(ctx.GetData()) != "hello room" || ctx.GetServiceId() != 1 || ctx.GetMethodId() != 2 {
+			t.Errorf("recv %v, want hello room", ctx)
+		}
+	}
+
+	// 全服广播
+	if n := s.Broadcast(1, 3, []byte("hello all")); n != 3 {
+		t.Errorf("server broadcast sent = %d, want 3", n)
+	}
+	for _, conn := range clients {
+		if ctx := readContext(t, conn); string(ctx.GetData()) != "hello all" {
+			t.Errorf("recv %v, want hello all", ctx)
+		}
+	}
+
+	// 链接断开后自动退出分组
+	clients[0].Close()
+	deadline := time.Now().Add(time.Second * 3)
+	for gm.Len("room") != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if n := gm.Len("room"); n != 1 {
+		t.Fatalf("group len after disconnect = %d, want 1", n)
+	}
+
+	member := gm.Members("room")[0]
+	gm.Leave("room", member)
+	if n := gm.Len("room"); n != 0 || len(gm.Groups(member)) != 0 {
+		t.Errorf("group len after leave = %d, groups = %v", n, gm.Groups(member))
+	}
+}
@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/status"
+	"github.com/treeforest/logger"
+	"reflect"
+)
+
+var (
+	requestType = reflect.TypeOf((*Request)(nil)).Elem()
+	messageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+/*
+	方法级的处理函数
+	handler 的形式为 func(req Request, in *XxxRequest) (*XxxResponse, error)，
+	框架负责解析请求、序列化响应、设置返回码并回复客户端
+*/
+type methodHandler struct {
+	// 处理函数
+	fn reflect.Value
+
+	// 请求参数的类型
+	inType reflect.Type
+}
+
+func newMethodHandler(handler interface{}) (*methodHandler, error) {
+	fn := reflect.ValueOf(handler)
+	t := fn.Type()
+
+	if t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("handler must be a func, got %v", t)
+	}
+
+	if t.NumIn() != 2 || t.In(0) != requestType ||
+		t.In(1).Kind() != reflect.Ptr || !t.In(1).Implements(messageType) {
+		return nil, fmt.Errorf("handler %v must have params (transport.Request, proto.Message)", t)
+	}
+
+	if t.NumOut() != 2 || !t.Out(0).Implements(messageType) || t.Out(1) != errorType {
+		return nil, fmt.Errorf("handler %v must return (proto.Message, error)", t)
+	}
+
+	return &methodHandler{fn: fn, inType: t.In(1).Elem()}, nil
+}
+
+// 解析请求数据并调用处理函数
+func (m *methodHandler) call(req Request) (proto.Message, error) {
+	in := reflect.New(m.inType).Interface().(proto.Message)
+	if err := proto.Unmarshal(req.GetContext().GetData(), in); err != nil {
+		return nil, NewError(context.Code_ERR_UNMARSHAL_REQUEST, err.Error())
+	}
+
+	out := m.fn.Call([]reflect.Value{reflect.ValueOf(req), reflect.ValueOf(in)})
+
+	var err error
+	if e := out[1].Interface(); e != nil {
+		err = e.(error)
+	}
+
+	if out[0].IsNil() {
+		return nil, err
+	}
+	return out[0].Interface().(proto.Message), err
+}
+
+// 将处理结果回复给客户端，沿用请求的上下文，序列号(seq)原样带回
+func reply(req Request, resp proto.Message, err error) {
+	ctx := req.GetContext()
+
+	var data []byte
+	if err == nil && resp != nil {
+		if data, err = proto.Marshal(resp); err != nil {
+			err = NewError(context.Code_ERR_HANDLE, err.Error())
+		}
+	}
+
+	// 请求的元数据不再回复给客户端
+	ctx.Metadata = nil
+	if err != nil {
+		log.Warnf("handle serviceID = %d methodID = %d error: %v", req.GetServiceID(), req.GetMethodID(), err)
+		data = nil
+	}
+	setResult(ctx, err)
+
+	if err := req.GetConnection().Send(ctx, data); err != nil {
+		log.Warnf("reply serviceID = %d methodID = %d error: %v", req.GetServiceID(), req.GetMethodID(), err)
+	}
+}
+
+// 根据处理结果设置返回码与错误信息
+func setResult(ctx *context.Context, err error) {
+	ctx.Status = nil
+	if err != nil {
+		s, ok := status.FromError(err)
+		if !ok {
+			// 未携带错误信息的错误可能包含内部细节，不回复给客户端
+			s = status.FromCode(context.Code_ERR_HANDLE, "")
+		}
+		ctx.Status = s.Proto()
+	}
+	ctx.Result = ErrorCode(err)
+}
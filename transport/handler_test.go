@@ -0,0 +1,174 @@
+package transport
+
+import (
+	gocontext "context"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/status"
+	"testing"
+	"time"
+)
+
+// 测试方法级处理函数的调度与错误码
+func TestRegisterHandler(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	s.RegisterHandler(7, 1, func(req Request, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+		return &wrappers.StringValue{Value: "hello " + in.Value}, nil
+	})
+	s.RegisterHandler(7, 2, func(req Request, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+		return nil, errors.New("something wrong")
+	})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+
+	call := func(serviceID, methodID uint32, data []byte) client.Message {
+		c.Send(serviceID, methodID, data)
+		msg := recvTimeout(c, time.Second*3)
+		if msg == nil {
+			t.Fatalf("serviceID = %d methodID = %d recv message timeout", serviceID, methodID)
+		}
+		return msg
+	}
+
+	in, _ := proto.Marshal(&wrappers.StringValue{Value: "gos"})
+
+	msg := call(7, 1, in)
+	out := new(wrappers.StringValue)
+	if err := proto.Unmarshal(msg.GetData(), out); err != nil {
+		t.Fatal(err)
+	}
+	if msg.GetContext().GetResult() != context.Code_SUCCESS || out.Value != "hello gos" {
+		t.Errorf("recv result = %v, value = %q", msg.GetContext().GetResult(), out.Value)
+	}
+
+	tests := []struct {
+		serviceID, methodID uint32
+		data                []byte
+		code                context.Code
+	}{
+		{7, 2, in, context.Code_ERR_HANDLE},
+		{7, 1, []byte{0xff}, context.Code_ERR_UNMARSHAL_REQUEST},
+		{7, 3, in, context.Code_ERR_METHOD_NOT_FOUND},
+		{8, 1, in, context.Code_ERR_SERVICE_NOT_FOUND},
+	}
+	for _, test := range tests {
+		msg := call(test.serviceID, test.methodID, test.data)
+		if code := msg.GetContext().GetResult(); code != test.code {
+			t.Errorf("serviceID = %d methodID = %d result = %v, want %v", test.serviceID, test.methodID, code, test.code)
+		}
+		if msg.GetServiceID() != test.serviceID || msg.GetMethodID() != test.methodID {
+			t.Errorf("reply serviceID = %d methodID = %d, want %d %d",
+				msg.GetServiceID(), msg.GetMethodID(), test.serviceID, test.methodID)
+		}
+	}
+}
+
+// 测试处理函数返回的错误信息完整地回复给客户端
+func TestStatusError(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	s.RegisterHandler(7, 1, func(req Request, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+		st, err := status.New(1001, "balance not enough").WithDetails(&wrappers.Int64Value{Value: 42})
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.Err()
+	})
+	s.RegisterHandler(7, 2, func(req Request, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+		return nil, errors.New("db password is wrong")
+	})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Second*3)
+	defer cancel()
+
+	err := c.Call(ctx, 7, 1, &wrappers.StringValue{}, new(wrappers.StringValue))
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != 1001 || st.Message() != "balance not enough" {
+		t.Fatalf("call error = %v", err)
+	}
+	if details := st.Details(); len(details) != 1 || details[0].(*wrappers.Int64Value).Value != 42 {
+		t.Errorf("details = %v", details)
+	}
+
+	// 未携带错误信息的错误不向客户端暴露细节
+	err = c.Call(ctx, 7, 2, &wrappers.StringValue{}, new(wrappers.StringValue))
+	st, ok = status.FromError(err)
+	if !ok || st.ContextCode() != context.Code_ERR_HANDLE || st.Message() != "" {
+		t.Errorf("call error = %v", err)
+	}
+}
+
+// 测试注册非法的处理函数
+func TestRegisterInvalidHandler(t *testing.T) {
+	handlers := []interface{}{
+		"not a func",
+		func(in *wrappers.StringValue) (*wrappers.StringValue, error) { return nil, nil },
+		func(req Request, in string) (*wrappers.StringValue, error) { return nil, nil },
+		func(req Request, in *wrappers.StringValue) *wrappers.StringValue { return nil },
+	}
+
+	for i, handler := range handlers {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("handler %d should be rejected", i)
+				}
+			}()
+			NewMessageHandler(1).RegisterHandler(1, uint32(i), handler)
+		}()
+	}
+}
+
+// 测试处理请求时发生 panic：回复内部错误，worker 继续工作，并调用 panicHandler
+func TestHandlerPanic(t *testing.T) {
+	panics := make(chan interface{}, 2)
+	s := NewServer(WithAddress("127.0.0.1", 0), WithWorkerPoolSize(1),
+		WithPanicHandler(func(req Request, r interface{}, stack []byte) {
+			panics <- r
+		}))
+	s.RegisterHandler(7, 1, func(req Request, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+		panic("handler panic")
+	})
+	s.RegisterHandler(7, 2, func(req Request, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+		return in, nil
+	})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+
+	in, _ := proto.Marshal(&wrappers.StringValue{Value: "gos"})
+	for _, methodID := range []uint32{1, 1, 2} {
+		c.Send(7, methodID, in)
+		msg := recvTimeout(c, time.Second*3)
+		if msg == nil {
+			t.Fatalf("methodID = %d recv message timeout", methodID)
+		}
+
+		want := context.Code_SUCCESS
+		if methodID == 1 {
+			want = context.Code_ERR_INTERNAL
+		}
+		if code := msg.GetContext().GetResult(); code != want {
+			t.Errorf("methodID = %d result = %v, want %v", methodID, code, want)
+		}
+	}
+
+	if n := len(panics); n != 2 {
+		t.Fatalf("panic handler called %d times, want 2", n)
+	}
+	if r := <-panics; r != "handler panic" {
+		t.Errorf("panic value = %v", r)
+	}
+}
@@ -0,0 +1,132 @@
+package transport
+
+import (
+	"crypto/rand"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/codec"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/utils/config/secrets"
+	"github.com/treeforest/gos/utils/config/secrets/box"
+	"github.com/treeforest/logger"
+	"sync/atomic"
+)
+
+// box 公钥与会话密钥的长度
+const keyLength = 32
+
+/*
+	握手
+
+	客户端建立链接后发送 HANDSHAKE 消息，data 为序列化的 context.Handshake：
+	compressions 为客户端按偏好排列的压缩算法(codec.Compression)，服务器选择其中第一个自身支持的算法；
+	publicKey 不为空时请求加密，服务器随机生成该链接的会话密钥，使用 box(服务器私钥、客户端公钥)加密后
+	连同服务器公钥一起回复。握手回复本身为明文，此后双方的数据包均带有 codec.FlagEncrypted 标志，
+	先压缩后加密，加密链接上收到明文数据包时关闭链接。双方由会话密钥派生各自方向的密钥，使用递增计数器作为 nonce，
+	重放、反射或篡改包头的数据包无法通过认证(见 codec.WithSessionKey)。
+
+	压缩算法与加密标志记录在每个数据包的包头中，双方在握手完成前收到的压缩数据同样能够解压。
+
+	返回绑定了会话密钥的封包、拆包模块(未加密时为空)，握手失败时返回 false，链接随之关闭
+*/
+func (c *connection) handshake(data []byte, encrypted bool) (DataPacker, bool) {
+	if encrypted {
+		// 会话密钥协商后不允许更换
+		log.Warnf("connID = %d repeated handshake on encrypted connection", c.connID)
+		return nil, false
+	}
+
+	hs := new(context.Handshake)
+	if err := proto.Unmarshal(data, hs); err != nil {
+		log.Warnf("connID = %d unmarshal handshake error: %v", c.connID, err)
+		c.SendErrCode(context.Code_ERR_HANDSHAKE)
+		return nil, false
+	}
+
+	opts := c.server.GetOptions()
+	packer := c.server.GetDataPacker()
+	compression := negotiate(hs.GetCompressions(), opts.Compressions, packer)
+	reply := &context.Handshake{Compressions: []uint32{uint32(compression)}}
+
+	var sealed DataPacker
+	if len(hs.GetPublicKey()) > 0 && opts.Encryption {
+		var err error
+		sealed, reply.SessionKey, err = newSession(packer, opts, hs.GetPublicKey())
+		if err != nil {
+			log.Warnf("connID = %d create session error: %v", c.connID, err)
+			c.SendErrCode(context.Code_ERR_HANDSHAKE)
+			return nil, false
+		}
+		reply.PublicKey = opts.BoxPublicKey
+	}
+
+	replyData, err := proto.Marshal(reply)
+	if err != nil {
+		log.Warnf("connID = %d marshal handshake error: %v", c.connID, err)
+		return nil, false
+	}
+
+	ctx := globalPool.GetContext()
+	ctx.Type = context.Type_HANDSHAKE
+	defer globalPool.PutContext(ctx)
+
+	// 回复以明文发送，随后再启用压缩与会话密钥
+	if err := c.Send(ctx, replyData); err != nil {
+		log.Warnf("connID = %d send handshake error: %v", c.connID, err)
+		return nil, false
+	}
+
+	atomic.StoreUint32(&c.compression, uint32(compression))
+	if sealed != nil {
+		c.lock.Lock()
+		c.sealed = sealed
+		c.lock.Unlock()
+	}
+	log.Debugf("connID = %d handshake compression = %s encrypted = %t", c.connID, compression, sealed != nil)
+
+	return sealed, true
+}
+
+// 从客户端按偏好排列的压缩算法中选择第一个服务器支持的算法，包格式不支持压缩时不压缩
+func negotiate(offered []uint32, supported []codec.Compression, packer DataPacker) codec.Compression {
+	if !codec.SupportsCompression(packer) {
+		return codec.CompressNone
+	}
+
+	for _, o := range offered {
+		for _, s := range supported {
+			if o == uint32(s) && s.Valid() {
+				return s
+			}
+		}
+	}
+	return codec.CompressNone
+}
+
+// 随机生成链接的会话密钥，返回绑定了该密钥的封包、拆包模块及使用 box 加密后的会话密钥
+func newSession(packer DataPacker, opts ServerOptions, peerPublicKey []byte) (DataPacker, []byte, error) {
+	if len(peerPublicKey) != keyLength {
+		return nil, nil, errors.New("invalid public key")
+	}
+
+	key := make([]byte, keyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+
+	sealed, err := codec.WithSessionKey(packer, key, codec.RoleServer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b := box.NewSecrets(secrets.PublicKey(opts.BoxPublicKey), secrets.PrivateKey(opts.BoxPrivateKey))
+	if err := b.Init(); err != nil {
+		return nil, nil, err
+	}
+	sessionKey, err := b.Encrypt(key, secrets.RecipientPublicKey(peerPublicKey))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sealed, sessionKey, nil
+}
@@ -0,0 +1,196 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/rand"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/codec"
+	"github.com/treeforest/gos/transport/context"
+	naclbox "golang.org/x/crypto/nacl/box"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 使用 packer 在 conn 上直接收发上下文
+func rawConn(t *testing.T, conn net.Conn, packer DataPacker) (func(ctx *context.Context), func() (*codec.Frame, *context.Context)) {
+	send := func(ctx *context.Context) {
+		data, _ := proto.Marshal(ctx)
+		buf, err := packer.Pack(&codec.Frame{Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recv := func() (*codec.Frame, *context.Context) {
+		conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+		f, err := packer.Unpack(conn)
+		if err != nil {
+			t.Fatalf("unpack error: %v", err)
+		}
+		ctx := new(context.Context)
+		if err := proto.Unmarshal(f.Data, ctx); err != nil {
+			t.Fatal(err)
+		}
+		return f, ctx
+	}
+	return send, recv
+}
+
+// 测试压缩算法的协商
+func TestNegotiate(t *testing.T) {
+	versioned := codec.NewVersionedPacker(0)
+	supported := []codec.Compression{codec.CompressZstd, codec.CompressGzip}
+
+	for _, tc := range []struct {
+		offered []codec.Compression
+		packer  DataPacker
+		want    codec.Compression
+	}{
+		{[]codec.Compression{codec.CompressSnappy, codec.CompressGzip, codec.CompressZstd}, versioned, codec.CompressGzip},
+		{[]codec.Compression{codec.CompressSnappy}, versioned, codec.CompressNone},
+		{nil, versioned, codec.CompressNone},
+		{[]codec.Compression{codec.CompressZstd}, codec.NewLegacyPacker(0), codec.CompressNone},
+	} {
+		offered := make([]uint32, len(tc.offered))
+		for i, c := range tc.offered {
+			offered[i] = uint32(c)
+		}
+		if got := negotiate(offered, supported, tc.packer); got != tc.want {
+			t.Errorf("negotiate(%v) = %s, want %s", tc.offered, got, tc.want)
+		}
+	}
+}
+
+// 测试握手后服务器压缩超过阈值的响应
+func TestHandshakeCompression(t *testing.T) {
+	packer := codec.NewVersionedPacker(4096)
+	s := NewServer(WithAddress("127.0.0.1", 0), WithPacker(packer),
+		WithCompression(64, codec.CompressZstd, codec.CompressGzip))
+	s.RegisterRouter(1, &echoRouter{})
+	s.Start()
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	send, recv := rawConn(t, conn, packer)
+
+	hs, _ := proto.Marshal(&context.Handshake{Compressions: []uint32{uint32(codec.CompressSnappy), uint32(codec.CompressGzip)}})
+	send(&context.Context{Type: context.Type_HANDSHAKE, Data: hs})
+	_, ctx := recv()
+	reply := new(context.Handshake)
+	proto.Unmarshal(ctx.GetData(), reply)
+	if ctx.GetType() != context.Type_HANDSHAKE || len(reply.GetCompressions()) != 1 || reply.GetCompressions()[0] != uint32(codec.CompressGzip) {
+		t.Fatalf("handshake reply = %v, want gzip", reply)
+	}
+
+	// 小于阈值的消息不压缩
+	send(&context.Context{ServiceId: 1, Data: []byte("hello")})
+	if f, ctx := recv(); f.Flags&codec.FlagCompressMask != 0 || string(ctx.GetData()) != "hello" {
+		t.Errorf("small message flags = %d data = %q", f.Flags, ctx.GetData())
+	}
+
+	large := bytes.Repeat([]byte("hello"), 200)
+	send(&context.Context{ServiceId: 1, Data: large})
+	if f, ctx := recv(); codec.Compression(f.Flags&codec.FlagCompressMask) != codec.CompressGzip || !bytes.Equal(ctx.GetData(), large) {
+		t.Errorf("large message flags = %d, want gzip", f.Flags)
+	}
+}
+
+// 测试客户端协商压缩并透明解压
+func TestClientCompression(t *testing.T) {
+	packer := codec.NewVersionedPacker(4096)
+	s := NewServer(WithAddress("127.0.0.1", 0), WithPacker(packer),
+		WithCompression(64, codec.CompressSnappy, codec.CompressZstd))
+	s.RegisterRouter(1, &echoRouter{})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient(client.WithPacker(packer), client.WithCompression(64, codec.CompressZstd))
+	c.Dial(s.Addr().String())
+
+	large := bytes.Repeat([]byte("hello"), 200)
+	c.Send(1, 2, large)
+	msg := recvTimeout(c, time.Second*3)
+	if msg == nil {
+		t.Fatal("recv message timeout")
+	}
+	if !bytes.Equal(msg.GetData(), large) {
+		t.Errorf("recv data len = %d, want %d", len(msg.GetData()), len(large))
+	}
+}
+
+// 测试加密链接
+func TestEncryption(t *testing.T) {
+	publicKey, privateKey, _ := naclbox.GenerateKey(rand.Reader)
+	packer := codec.NewVersionedPacker(4096)
+	s := NewServer(WithAddress("127.0.0.1", 0), WithPacker(packer),
+		WithEncryption(publicKey[:], privateKey[:]), WithRequireEncryption(),
+		WithCompression(64, codec.CompressZstd))
+	s.RegisterRouter(1, &echoRouter{})
+	s.Start()
+	defer s.Stop()
+
+	t.Run("Client", func(t *testing.T) {
+		c := client.NewClient(client.WithPacker(packer), client.WithEncryption(publicKey[:]),
+			client.WithCompression(64, codec.CompressZstd))
+		c.Dial(s.Addr().String())
+
+		for _, data := range [][]byte{[]byte("hello"), bytes.Repeat([]byte("hello"), 200)} {
+			c.Send(1, 2, data)
+			msg := recvTimeout(c, time.Second*3)
+			if msg == nil {
+				t.Fatal("recv message timeout")
+			}
+			if !bytes.Equal(msg.GetData(), data) {
+				t.Errorf("recv data len = %d, want %d", len(msg.GetData()), len(data))
+			}
+		}
+	})
+
+	// 服务器公钥不匹配时客户端断开链接
+	t.Run("PublicKeyMismatch", func(t *testing.T) {
+		otherKey, _, _ := naclbox.GenerateKey(rand.Reader)
+		c := client.NewClient(client.WithPacker(packer), client.WithEncryption(otherKey[:]))
+		c.Dial(s.Addr().String())
+		c.Send(1, 2, []byte("hello"))
+		if msg := recvTimeout(c, time.Millisecond*300); msg != nil {
+			t.Errorf("recv message %v, want nothing", msg.GetContext())
+		}
+	})
+
+	// 未设置服务器公钥时无法校验服务器身份，拒绝建立加密链接
+	t.Run("NoPublicKey", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("dial without server public key should panic")
+			}
+		}()
+		c := client.NewClient(client.WithPacker(packer), client.WithEncryption(nil))
+		c.Dial(s.Addr().String())
+	})
+
+	// 要求加密时，明文请求导致链接被关闭
+	t.Run("Plaintext", func(t *testing.T) {
+		conn, err := net.Dial("tcp", s.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		send, _ := rawConn(t, conn, packer)
+		send(&context.Context{ServiceId: 1, Data: []byte("hello")})
+		conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+		if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+			t.Errorf("plaintext request should close the connection, read error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/logger"
+	"sync/atomic"
+	"time"
+)
+
+// 空闲链接检测的最小间隔
+const minReapInterval = time.Millisecond * 10
+
+/*
+	空闲链接回收
+
+	定期检查所有链接最后一次收到完整数据包的时间：
+	超过 HeartbeatInterval 时向客户端发送 PING，客户端回复 PONG 即视为活跃；
+	超过 ReadIdleTimeout 时通过 Stop 关闭链接，OnConnStop 照常调用。
+	只发送了半个数据包的链接同样会被关闭
+*/
+func (s *server) startReaper() {
+	interval := s.opts.ReadIdleTimeout
+	if s.opts.HeartbeatInterval > 0 && (interval == 0 || s.opts.HeartbeatInterval < interval) {
+		interval = s.opts.HeartbeatInterval
+	}
+	if interval == 0 {
+		return
+	}
+
+	interval /= 2
+	if interval < minReapInterval {
+		interval = minReapInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.reap()
+			case <-s.exitChan:
+				return
+			}
+		}
+	}()
+}
+
+func (s *server) reap() {
+	now := time.Now()
+	s.connMgr.Range(func(conn Connection) bool {
+		idle := now.Sub(conn.GetLastActiveTime())
+
+		if s.opts.ReadIdleTimeout > 0 && idle > s.opts.ReadIdleTimeout {
+			// Stop 可能等待 writer 写出剩余消息，移除之前的检测中跳过已在关闭的链接
+			if c, ok := conn.(*connection); ok && !atomic.CompareAndSwapInt32(&c.reaping, 0, 1) {
+				return true
+			}
+			log.Infof("connID = %d idle for %v, close it", conn.GetConnID(), idle)
+			go conn.Stop()
+			return true
+		}
+
+		if s.opts.HeartbeatInterval > 0 && idle > s.opts.HeartbeatInterval {
+			if c, ok := conn.(*connection); ok {
+				c.sendHeartbeat(context.Type_PING)
+			}
+		}
+		return true
+	})
+}
+
+// 发送心跳消息，发送队列已满时直接丢弃，不阻塞调用方
+func (c *connection) sendHeartbeat(t context.Type) {
+	if c.isClosed() {
+		return
+	}
+
+	ctx := globalPool.GetContext()
+	ctx.Type = t
+	defer globalPool.PutContext(ctx)
+
+	data, err := c.packContext(ctx)
+	if err != nil {
+		log.Warnf("connID = %d pack %s error: %v", c.connID, t, err)
+		return
+	}
+
+	select {
+	case c.msgChan <- data:
+	default:
+	}
+}
@@ -0,0 +1,148 @@
+package transport
+
+import (
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/codec"
+	"github.com/treeforest/gos/transport/context"
+	"io"
+	"net"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试回收只发送了半个数据包头部的空闲链接
+func TestReapIdleConn(t *testing.T) {
+	var stopped int32
+	reaped := make(chan Connection, 1)
+	s := NewServer(WithAddress("127.0.0.1", 0), WithIdleTimeout(time.Millisecond*200, time.Second))
+	s.SetOnConnStopFunc(func(c Connection) {
+		if atomic.AddInt32(&stopped, 1) == 1 {
+			reaped <- c
+		}
+	})
+	s.Start()
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte{1, 2, 3})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("idle connection should be closed by server, read error: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&stopped) != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if n := atomic.LoadInt32(&stopped); n != 1 {
+		t.Errorf("OnConnStop called %d times, want 1", n)
+	}
+
+	// 被回收的链接对象不会被新的链接复用，仍持有其引用的一方不会把消息发给新的链接
+	old := <-reaped
+	oldID := old.GetConnID()
+	for i := 0; i < 3; i++ {
+		c, err := net.Dial("tcp", s.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+	waitConnNum(t, s, 3)
+	if id := old.GetConnID(); id != oldID {
+		t.Errorf("stopped connection id changed from %d to %d", oldID, id)
+	}
+	if err := old.Send(&context.Context{Type: context.Type_PUSH}, nil); err != ErrConnClosed {
+		t.Errorf("send on stopped connection error = %v, want %v", err, ErrConnClosed)
+	}
+}
+
+// 测试关闭耗时较长的空闲链接时，每次检测不会重复启动 Stop
+func TestReapSlowStop(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0), WithIdleTimeout(time.Millisecond*100, 0))
+	s.Start()
+	defer s.Stop()
+
+	// 不读取数据的客户端，Stop 等待 writer 写出剩余消息直到 flushTimeout
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitConnNum(t, s, 1)
+
+	s.GetConnManager().Range(func(c Connection) bool {
+		go func() {
+			data := make([]byte, 1024*1024)
+			for i := 0; i < 64; i++ {
+				if c.Send(&context.Context{ServiceId: 9, MethodId: 1}, data) != nil {
+					return
+				}
+			}
+		}()
+		return true
+	})
+	time.Sleep(time.Millisecond * 300)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		s.(*server).reap()
+	}
+	if n := runtime.NumGoroutine() - before; n > 10 {
+		t.Errorf("reap started %d goroutines for one idle connection", n)
+	}
+}
+
+// 测试服务器回复 PING
+func TestPingPong(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	s.Start()
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	data, _ := packContext(codec.NewLegacyPacker(0), &context.Context{Type: context.Type_PING})
+	conn.Write(data)
+
+	ctx := readContext(t, conn)
+	if ctx.GetType() != context.Type_PONG {
+		t.Errorf("recv type = %v, want %v", ctx.GetType(), context.Type_PONG)
+	}
+}
+
+// 测试客户端回复服务器的 PING 后，空闲的链接保持存活
+func TestHeartbeatKeepAlive(t *testing.T) {
+	var stopped int32
+	s := NewServer(WithAddress("127.0.0.1", 0),
+		WithIdleTimeout(time.Millisecond*400, time.Second), WithHeartbeat(time.Millisecond*100))
+	s.SetOnConnStopFunc(func(Connection) { atomic.AddInt32(&stopped, 1) })
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+
+	time.Sleep(time.Second)
+
+	if n := atomic.LoadInt32(&stopped); n != 0 {
+		t.Fatalf("connection answering heartbeats should not be closed")
+	}
+	if n := s.GetConnManager().Len(); n != 1 {
+		t.Errorf("conn num = %d, want 1", n)
+	}
+	if msg := c.Recv(); msg != nil {
+		t.Errorf("heartbeat should not be delivered to caller, got %v", msg.GetContext())
+	}
+}
@@ -0,0 +1,39 @@
+package transport
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+/*
+	拦截器
+
+	请求在交给 Router 或处理函数之前依次经过全局拦截器和服务级拦截器，
+	拦截器可以检查请求、记录耗时、修改响应，或者直接返回错误中断调用链，例如：
+
+	func auth(req Request, next Invoker) (proto.Message, error) {
+		if _, ok := req.GetConnection().GetProperty("uid"); !ok {
+			return nil, NewError(context.Code_ERR_HANDLE, "unauthorized")
+		}
+		return next(req)
+	}
+
+	对于 Router 处理的请求，next 返回 (nil, nil)，回复由 Router 自行完成；
+	拦截器返回错误或非空的响应时，框架会将其回复给客户端
+
+	流式调用在打开时同样经过拦截器，此时 req 为打开流的请求(Type 为 STREAM_OPEN)，
+	next 执行整个流式处理函数并返回 (nil, 处理函数的错误)；拦截器返回的错误作为流的返回码
+*/
+type Invoker func(req Request) (proto.Message, error)
+
+type Interceptor func(req Request, next Invoker) (proto.Message, error)
+
+// 将拦截器按顺序串联在 invoker 之前，interceptors[0] 最先执行
+func chainInterceptors(interceptors []Interceptor, invoker Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(req Request) (proto.Message, error) {
+			return interceptor(req, next)
+		}
+	}
+	return invoker
+}
@@ -0,0 +1,97 @@
+package transport
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试全局拦截器与服务级拦截器的执行顺序、中断与修改响应
+func TestInterceptor(t *testing.T) {
+	var (
+		lock  sync.Mutex
+		trace []string
+	)
+	record := func(name string) Interceptor {
+		return func(req Request, next Invoker) (proto.Message, error) {
+			lock.Lock()
+			trace = append(trace, name)
+			lock.Unlock()
+			return next(req)
+		}
+	}
+
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	s.RegisterHandler(7, 1, func(req Request, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+		return &wrappers.StringValue{Value: "hello " + in.Value}, nil
+	})
+	s.RegisterRouter(1, &echoRouter{})
+
+	s.AddInterceptor(record("global1"), record("global2"))
+	s.AddServiceInterceptor(7, record("service7"), func(req Request, next Invoker) (proto.Message, error) {
+		// 拒绝 methodID = 2 的请求
+		if req.GetMethodID() == 2 {
+			return nil, NewError(context.Code_ERR_HANDLE, "forbidden")
+		}
+		// 修改响应
+		resp, err := next(req)
+		if out, ok := resp.(*wrappers.StringValue); ok {
+			out.Value += "!"
+		}
+		return resp, err
+	})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+
+	in, _ := proto.Marshal(&wrappers.StringValue{Value: "gos"})
+
+	c.Send(7, 1, in)
+	msg := recvTimeout(c, time.Second*3)
+	if msg == nil {
+		t.Fatal("recv message timeout")
+	}
+	out := new(wrappers.StringValue)
+	proto.Unmarshal(msg.GetData(), out)
+	if out.Value != "hello gos!" {
+		t.Errorf("recv value = %q, want %q", out.Value, "hello gos!")
+	}
+
+	lock.Lock()
+	if got := trace; len(got) != 3 || got[0] != "global1" || got[1] != "global2" || got[2] != "service7" {
+		t.Errorf("interceptor trace = %v", got)
+	}
+	trace = nil
+	lock.Unlock()
+
+	// 拦截器中断调用链
+	c.Send(7, 2, in)
+	if msg := recvTimeout(c, time.Second*3); msg == nil {
+		t.Fatal("recv message timeout")
+	} else if code := msg.GetContext().GetResult(); code != context.Code_ERR_HANDLE {
+		t.Errorf("recv result = %v, want %v", code, context.Code_ERR_HANDLE)
+	}
+
+	// Router 的请求仅经过全局拦截器，由 Router 自行回复
+	c.Send(1, 2, []byte("hello"))
+	if msg := recvTimeout(c, time.Second*3); msg == nil {
+		t.Fatal("recv message timeout")
+	} else if string(msg.GetData()) != "hello" {
+		t.Errorf("recv data = %q, want %q", msg.GetData(), "hello")
+	}
+	if msg := recvTimeout(c, time.Millisecond*200); msg != nil {
+		t.Errorf("router request should be replied only once")
+	}
+
+	lock.Lock()
+	if got := trace; len(got) != 5 || got[2] != "service7" || got[3] != "global1" || got[4] != "global2" {
+		t.Errorf("interceptor trace = %v", got)
+	}
+	lock.Unlock()
+}
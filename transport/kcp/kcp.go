@@ -0,0 +1,107 @@
+// Package kcp 提供基于 KCP 协议(可靠UDP)的监听器与链接。
+// KCP 会话以流模式工作，对上层表现为普通的 net.Conn，
+// 因此可以直接复用TCP链接的封包、拆包与消息处理流程。
+package kcp
+
+import (
+	kcpgo "github.com/xtaci/kcp-go/v5"
+	"net"
+)
+
+// KCP 协议参数
+type Config struct {
+	// 是否启用 nodelay 模式，0 不启用，1 启用
+	NoDelay int
+
+	// 协议内部工作的 interval，单位毫秒
+	Interval int
+
+	// 快速重传模式，0 关闭，2 表示2次ACK跨越将会直接重传
+	Resend int
+
+	// 是否关闭流控，0 不关闭，1 关闭
+	NoCongestion int
+
+	// 发送窗口与接收窗口大小，单位为包
+	SndWnd int
+	RcvWnd int
+
+	// 最大传输单元
+	MTU int
+}
+
+// 默认使用低延迟的极速模式
+func DefaultConfig() Config {
+	return Config{
+		NoDelay:      1,
+		Interval:     10,
+		Resend:       2,
+		NoCongestion: 1,
+		SndWnd:       128,
+		RcvWnd:       128,
+		MTU:          1400,
+	}
+}
+
+// 按照配置设置 KCP 会话参数
+func (c Config) apply(sess *kcpgo.UDPSession) {
+	sess.SetStreamMode(true)
+	sess.SetNoDelay(c.NoDelay, c.Interval, c.Resend, c.NoCongestion)
+	sess.SetWindowSize(c.SndWnd, c.RcvWnd)
+	sess.SetMtu(c.MTU)
+	sess.SetACKNoDelay(true)
+	sess.SetWriteDelay(false)
+}
+
+// KCP 监听器，接受的会话均按照 Config 设置参数
+type listener struct {
+	*kcpgo.Listener
+	config Config
+}
+
+// 在 address 上监听 KCP 链接
+func Listen(address string, config Config) (net.Listener, error) {
+	l, err := kcpgo.ListenWithOptions(address, nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &listener{Listener: l, config: config}, nil
+}
+
+// 在已经创建好的 PacketConn 上监听 KCP 链接，关闭监听器时不会关闭 conn
+func Serve(conn net.PacketConn, config Config) (net.Listener, error) {
+	l, err := kcpgo.ServeConn(nil, 0, 0, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &listener{Listener: l, config: config}, nil
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	sess, err := l.AcceptKCP()
+	if err != nil {
+		return nil, err
+	}
+	l.config.apply(sess)
+	return sess, nil
+}
+
+// 连接 KCP 服务器
+func Dial(address string, config Config) (net.Conn, error) {
+	sess, err := kcpgo.DialWithOptions(address, nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	config.apply(sess)
+	return sess, nil
+}
+
+// 在已经创建好的 PacketConn 上连接 KCP 服务器
+func NewConn(address string, conn net.PacketConn, config Config) (net.Conn, error) {
+	sess, err := kcpgo.NewConn(address, nil, 0, 0, conn)
+	if err != nil {
+		return nil, err
+	}
+	config.apply(sess)
+	return sess, nil
+}
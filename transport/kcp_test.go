@@ -0,0 +1,117 @@
+package transport
+
+import (
+	"fmt"
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/kcp"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 模拟丢包与延迟的 PacketConn
+type lossyConn struct {
+	net.PacketConn
+
+	// 丢包率
+	loss float64
+
+	// 单向延迟及其抖动
+	delay  time.Duration
+	jitter time.Duration
+
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+func newLossyConn(conn net.PacketConn, loss float64, delay, jitter time.Duration) *lossyConn {
+	return &lossyConn{
+		PacketConn: conn,
+		loss:       loss,
+		delay:      delay,
+		jitter:     jitter,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (c *lossyConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.lock.Lock()
+	drop := c.rand.Float64() < c.loss
+	delay := c.delay + time.Duration(c.rand.Int63n(int64(c.jitter)+1))
+	c.lock.Unlock()
+
+	if drop {
+		return len(p), nil
+	}
+
+	data := make([]byte, len(p))
+	copy(data, p)
+	time.AfterFunc(delay, func() {
+		c.PacketConn.WriteTo(data, addr)
+	})
+	return len(p), nil
+}
+
+// 测试在丢包、延迟的环境下 KCP 链接的可靠、有序传输
+func TestKCPWithLoss(t *testing.T) {
+	serverConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverConn.Close()
+
+	listener, err := kcp.Serve(newLossyConn(serverConn, 0.2, time.Millisecond*20, time.Millisecond*10), kcp.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 有序分发保证回复顺序与请求顺序一致，共享工作池下回复可能乱序
+	var started, stopped int32
+	s := NewServer(WithAddress("127.0.0.1", 0), WithListener(listener), WithOrderedDispatch(ShardByConn))
+	s.SetOnConnStartFunc(func(Connection) { atomic.AddInt32(&started, 1) })
+	s.SetOnConnStopFunc(func(Connection) { atomic.AddInt32(&stopped, 1) })
+	s.RegisterRouter(1, &echoRouter{})
+	s.Start()
+
+	clientConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+
+	conn, err := kcp.NewConn(serverConn.LocalAddr().String(),
+		newLossyConn(clientConn, 0.2, time.Millisecond*20, time.Millisecond*10), kcp.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := client.NewClient()
+	c.Connect(conn)
+
+	const count = 20
+	for i := 0; i < count; i++ {
+		c.Send(1, 2, []byte(fmt.Sprintf("message %d", i)))
+	}
+
+	for i := 0; i < count; i++ {
+		msg := recvTimeout(c, time.Second*10)
+		if msg == nil {
+			t.Fatalf("recv message %d timeout", i)
+		}
+		if want := fmt.Sprintf("message %d", i); string(msg.GetData()) != want {
+			t.Fatalf("recv data = %q, want %q", msg.GetData(), want)
+		}
+	}
+
+	if n := atomic.LoadInt32(&started); n != 1 {
+		t.Errorf("OnConnStart called %d times, want 1", n)
+	}
+
+	s.Stop()
+	if n := atomic.LoadInt32(&stopped); n != 1 {
+		t.Errorf("OnConnStop called %d times, want 1", n)
+	}
+}
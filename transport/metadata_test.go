@@ -0,0 +1,59 @@
+package transport
+
+import (
+	gocontext "context"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/treeforest/gos/client"
+	"testing"
+	"time"
+)
+
+// 测试请求与响应的元数据
+func TestMetadata(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	// 拦截器读取链路id并写入响应的元数据
+	s.AddInterceptor(func(req Request, next Invoker) (proto.Message, error) {
+		req.SetTrailer("trace-id", req.GetMetadata()["trace-id"])
+		return next(req)
+	})
+	s.RegisterHandler(7, 1, func(req Request, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+		return &wrappers.StringValue{Value: req.GetMetadata()["locale"] + " " + req.GetMetadata()["version"]}, nil
+	})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient(client.WithMetadata(map[string]string{"locale": "zh-CN", "version": "1.0"}))
+	c.Dial(s.Addr().String())
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Second*3)
+	defer cancel()
+
+	// 单次调用的元数据覆盖默认的元数据
+	var trailer map[string]string
+	out := new(wrappers.StringValue)
+	err := c.Call(ctx, 7, 1, &wrappers.StringValue{}, out,
+		client.Metadata("trace-id", "t1"), client.Metadata("version", "1.1"), client.Trailer(&trailer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != "zh-CN 1.1" {
+		t.Errorf("value = %q, want %q", out.Value, "zh-CN 1.1")
+	}
+	if trailer["trace-id"] != "t1" {
+		t.Errorf("trailer = %v", trailer)
+	}
+
+	// 修改默认的元数据，请求的元数据不随响应返回
+	c.SetMetadata("locale", "en-US")
+	msg, err := c.Go(7, 1, &wrappers.StringValue{}, client.Metadata("trace-id", "t2")).Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ParseResponse(msg, out); err != nil || out.Value != "en-US 1.0" {
+		t.Errorf("value = %q, %v", out.Value, err)
+	}
+	if len(msg.GetContext().GetMetadata()) != 0 || msg.GetTrailer()["trace-id"] != "t2" {
+		t.Errorf("metadata = %v, trailer = %v", msg.GetContext().GetMetadata(), msg.GetTrailer())
+	}
+}
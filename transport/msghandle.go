@@ -1,11 +1,19 @@
 package transport
 
 import (
+	gocontext "context"
 	"fmt"
-	"github.com/treeforest/gos/config"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/context"
 	"github.com/treeforest/logger"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
 )
 
+// 处理请求发生 panic 时调用的函数，r 为 recover 的返回值，stack 为 panic 时的调用栈
+type PanicHandler func(req Request, r interface{}, stack []byte)
+
 /*
 	消息处理模块的实现
 */
@@ -13,36 +21,197 @@ type messageHandle struct {
 	// 存放每个msgID所对应的处理方法
 	routerMap map[uint32]Router
 
-	// 工作池的消息队列
-	taskChan chan Request
+	// 存放每个(serviceID, methodID)所对应的处理函数
+	handlerMap map[uint64]*methodHandler
+
+	// 存放每个(serviceID, methodID)所对应的流式处理函数
+	streamHandlers map[uint64]StreamHandler
+
+	// 注册了处理函数的服务
+	serviceSet map[uint32]bool
+
+	// 全局拦截器
+	interceptors []Interceptor
+
+	// 每个serviceID所对应的拦截器
+	serviceInterceptors map[uint32][]Interceptor
+
+	// 处理请求发生 panic 时调用
+	panicHandler PanicHandler
+
+	// 每个serviceID所对应的优先级
+	servicePriority map[uint32]context.Priority
+
+	// 每个(serviceID, methodID)所对应的优先级
+	methodPriority map[uint64]context.Priority
+
+	// 是否采用客户端携带的优先级
+	clientPriority bool
+
+	// 工作池的消息队列，共享模式下所有worker共用一个队列，有序模式下每个worker一个队列
+	taskQueues []taskQueue
+
+	// 各优先级已处理的请求数
+	handled [priorityLanes]uint64
+
+	// 各优先级出队时已超时或被取消而丢弃的请求数
+	shedCount [priorityLanes]uint64
+
+	// 有序模式下选择消息队列的函数，为空时为共享模式
+	shard ShardFunc
 
 	// 业务工作Worker池的Worker数量
 	workerPoolSize uint32
+
+	// 保护 stopped 与 taskQueues 的关闭，避免向已关闭的队列投递任务
+	lock sync.RWMutex
+
+	// 工作池是否已停止接收任务
+	stopped bool
+
+	// 等待所有worker退出
+	wg sync.WaitGroup
+}
+
+// 创建消息处理模块，所有worker共用一个消息队列，请求的处理顺序不确定
+func NewMessageHandler(workerPoolSize uint32) MessageHandler {
+	h := newMessageHandle(workerPoolSize)
+	h.taskQueues = []taskQueue{newTaskQueue(workerPoolSize)}
+	return h
+}
+
+// 创建有序分发的消息处理模块，每个worker拥有长度为 maxWorkerTaskLen 的消息队列，
+// shard 返回值相同且优先级相同的请求由同一个worker按顺序处理
+func NewOrderedMessageHandler(workerPoolSize, maxWorkerTaskLen uint32, shard ShardFunc) MessageHandler {
+	h := newMessageHandle(workerPoolSize)
+	h.shard = shard
+	h.taskQueues = make([]taskQueue, workerPoolSize)
+	for i := range h.taskQueues {
+		h.taskQueues[i] = newTaskQueue(maxWorkerTaskLen)
+	}
+	return h
 }
 
-func NewMessageHandler() MessageHandler {
+func newMessageHandle(workerPoolSize uint32) *messageHandle {
 	return &messageHandle{
-		routerMap:      make(map[uint32]Router),
-		taskChan:       make(chan Request, config.ServerConfig.WorkerPoolSize),
-		workerPoolSize: config.ServerConfig.WorkerPoolSize,
+		routerMap:           make(map[uint32]Router),
+		handlerMap:          make(map[uint64]*methodHandler),
+		streamHandlers:      make(map[uint64]StreamHandler),
+		serviceSet:          make(map[uint32]bool),
+		serviceInterceptors: make(map[uint32][]Interceptor),
+		servicePriority:     make(map[uint32]context.Priority),
+		methodPriority:      make(map[uint64]context.Priority),
+		workerPoolSize:      workerPoolSize,
 	}
 }
 
-// 调度/执行对应的Router消息处理方法
+func methodKey(serviceID, methodID uint32) uint64 {
+	return uint64(serviceID)<<32 | uint64(methodID)
+}
+
+// 调度/执行对应的处理函数或Router消息处理方法
 func (h *messageHandle) HandleRequest(req Request) {
-	handler, ok := h.routerMap[req.GetServiceID()]
-	if !ok {
-		log.Errorf("HandleRequest serviceID = %d is not found!", req.GetServiceID())
+	defer func() {
+		// 单个请求的 panic 不影响worker及整个进程
+		if r := recover(); r != nil {
+			h.handlePanic(req, r)
+		}
+
+		// 回收临时对象资源
+		globalPool.PutContext(req.GetContext())
+		globalPool.PutRequest(req.(*request))
+	}()
+
+	// 在队列中等待期间已超时或被取消的请求不再处理，工作池中的请求通常已在出队时被丢弃
+	if err := req.Context().Err(); err != nil {
+		h.shed(req, err)
 		return
 	}
 
-	handler.PreHandle(req)
-	handler.Handle(req)
-	handler.PostHandle(req)
+	_, hasHandler := h.handlerMap[methodKey(req.GetServiceID(), req.GetMethodID())]
+
+	invoker := chainInterceptors(h.serviceInterceptors[req.GetServiceID()], h.invoke)
+	invoker = chainInterceptors(h.interceptors, invoker)
+
+	resp, err := invoker(req)
+
+	// 处理函数的结果总是回复给客户端；Router 自行回复，
+	// 仅当拦截器中断调用或返回了响应时才由框架回复
+	if hasHandler || err != nil || resp != nil {
+		reply(req, resp, err)
+	}
+}
+
+/*
+	丢弃已超时或被取消的请求
+	超时的请求回复 ERR_DEADLINE_EXCEEDED；被取消的请求(客户端取消或链接断开)无需回复
+*/
+func (h *messageHandle) shed(req Request, err error) {
+	log.Debugf("shed request connID = %d serviceID = %d methodID = %d: %v",
+		req.GetConnection().GetConnID(), req.GetServiceID(), req.GetMethodID(), err)
+
+	if err == gocontext.DeadlineExceeded {
+		reply(req, nil, NewError(context.Code_ERR_DEADLINE_EXCEEDED, "deadline exceeded before handling"))
+	}
+}
+
+// 出队时丢弃已超时或被取消的请求，不再经过拦截器与处理函数，返回是否已丢弃
+func (h *messageHandle) shedExpired(req Request, lane int) bool {
+	err := req.Context().Err()
+	if err == nil {
+		return false
+	}
+
+	h.shed(req, err)
+	atomic.AddUint64(&h.shedCount[lane], 1)
 
-	// 回收临时对象资源
 	globalPool.PutContext(req.GetContext())
 	globalPool.PutRequest(req.(*request))
+	return true
+}
+
+// 记录 panic 的调用栈，通知调用方服务器内部错误，并调用 panicHandler
+func (h *messageHandle) handlePanic(req Request, r interface{}) {
+	stack := debug.Stack()
+	log.Errorf("HandleRequest panic connID = %d serviceID = %d methodID = %d: %v\n%s",
+		req.GetConnection().GetConnID(), req.GetServiceID(), req.GetMethodID(), r, stack)
+
+	reply(req, nil, NewError(context.Code_ERR_INTERNAL, "internal error"))
+
+	if h.panicHandler != nil {
+		func() {
+			// panicHandler 自身的 panic 同样不能影响worker
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("panic handler panic: %v", r)
+				}
+			}()
+			h.panicHandler(req, r, stack)
+		}()
+	}
+}
+
+// 调用链的末端：执行处理函数或Router
+func (h *messageHandle) invoke(req Request) (proto.Message, error) {
+	// 优先使用方法级的处理函数
+	if handler, ok := h.handlerMap[methodKey(req.GetServiceID(), req.GetMethodID())]; ok {
+		return handler.call(req)
+	}
+
+	router, ok := h.routerMap[req.GetServiceID()]
+	if !ok {
+		if h.serviceSet[req.GetServiceID()] {
+			log.Errorf("HandleRequest serviceID = %d methodID = %d is not found!", req.GetServiceID(), req.GetMethodID())
+			return nil, NewError(context.Code_ERR_METHOD_NOT_FOUND, "method not found")
+		}
+		log.Errorf("HandleRequest serviceID = %d is not found!", req.GetServiceID())
+		return nil, NewError(context.Code_ERR_SERVICE_NOT_FOUND, "service not found")
+	}
+
+	router.PreHandle(req)
+	router.Handle(req)
+	router.PostHandle(req)
+	return nil, nil
 }
 
 // 为消息添加具体的处理逻辑
@@ -57,35 +226,203 @@ func (h *messageHandle) RegisterRouter(serviceID uint32, router Router) {
 	log.Infof("register router serviceID = %d success!", serviceID)
 }
 
+// 为(serviceID, methodID)注册处理函数
+func (h *messageHandle) RegisterHandler(serviceID, methodID uint32, handler interface{}) {
+	key := methodKey(serviceID, methodID)
+	if _, ok := h.handlerMap[key]; ok {
+		panic(fmt.Errorf("repeat handler, serviceID = %d methodID = %d", serviceID, methodID))
+	}
+	if _, ok := h.streamHandlers[key]; ok {
+		panic(fmt.Errorf("repeat handler, serviceID = %d methodID = %d is a stream handler", serviceID, methodID))
+	}
+
+	m, err := newMethodHandler(handler)
+	if err != nil {
+		panic(fmt.Errorf("register handler serviceID = %d methodID = %d error: %v", serviceID, methodID, err))
+	}
+
+	h.handlerMap[key] = m
+	h.serviceSet[serviceID] = true
+	log.Infof("register handler serviceID = %d methodID = %d success!", serviceID, methodID)
+}
+
+// 为(serviceID, methodID)注册流式处理函数
+func (h *messageHandle) RegisterStreamHandler(serviceID, methodID uint32, handler StreamHandler) {
+	key := methodKey(serviceID, methodID)
+	if _, ok := h.streamHandlers[key]; ok {
+		panic(fmt.Errorf("repeat stream handler, serviceID = %d methodID = %d", serviceID, methodID))
+	}
+	if _, ok := h.handlerMap[key]; ok {
+		panic(fmt.Errorf("repeat stream handler, serviceID = %d methodID = %d is a handler", serviceID, methodID))
+	}
+
+	h.streamHandlers[key] = handler
+	h.serviceSet[serviceID] = true
+	log.Infof("register stream handler serviceID = %d methodID = %d success!", serviceID, methodID)
+}
+
+// 获取(serviceID, methodID)的流式处理函数
+func (h *messageHandle) GetStreamHandler(serviceID, methodID uint32) (StreamHandler, error) {
+	if handler, ok := h.streamHandlers[methodKey(serviceID, methodID)]; ok {
+		return handler, nil
+	}
+	if _, ok := h.routerMap[serviceID]; ok || h.serviceSet[serviceID] {
+		return nil, NewError(context.Code_ERR_METHOD_NOT_FOUND, "stream method not found")
+	}
+	return nil, NewError(context.Code_ERR_SERVICE_NOT_FOUND, "service not found")
+}
+
+/*
+	执行流式处理函数
+	req 为打开流的请求，拦截器返回错误时不再执行处理函数，该错误作为流的返回码
+*/
+func (h *messageHandle) HandleStream(req Request, handle func() error) error {
+	invoker := chainInterceptors(h.serviceInterceptors[req.GetServiceID()], func(Request) (proto.Message, error) {
+		return nil, handle()
+	})
+	invoker = chainInterceptors(h.interceptors, invoker)
+
+	_, err := invoker(req)
+	return err
+}
+
+// 添加全局拦截器
+func (h *messageHandle) AddInterceptor(interceptors ...Interceptor) {
+	h.interceptors = append(h.interceptors, interceptors...)
+}
+
+// 添加服务级拦截器
+func (h *messageHandle) AddServiceInterceptor(serviceID uint32, interceptors ...Interceptor) {
+	h.serviceInterceptors[serviceID] = append(h.serviceInterceptors[serviceID], interceptors...)
+}
+
+// 设置处理请求发生 panic 时调用的函数
+func (h *messageHandle) SetPanicHandler(f PanicHandler) {
+	h.panicHandler = f
+}
+
+// 设置serviceID的请求优先级
+func (h *messageHandle) SetServicePriority(serviceID uint32, priority context.Priority) {
+	h.servicePriority[serviceID] = priority
+}
+
+// 设置(serviceID, methodID)的请求优先级
+func (h *messageHandle) SetMethodPriority(serviceID, methodID uint32, priority context.Priority) {
+	h.methodPriority[methodKey(serviceID, methodID)] = priority
+}
+
+// 设置是否采用客户端携带的请求优先级
+func (h *messageHandle) SetClientPriority(allow bool) {
+	h.clientPriority = allow
+}
+
+// 请求的优先级：方法级的设置优先于服务级的设置；都未设置时为 NORMAL，
+// 开启 SetClientPriority 后才使用客户端携带的优先级，避免客户端自行抢占高优先级队列
+func (h *messageHandle) priority(req Request) context.Priority {
+	if p, ok := h.methodPriority[methodKey(req.GetServiceID(), req.GetMethodID())]; ok {
+		return p
+	}
+	if p, ok := h.servicePriority[req.GetServiceID()]; ok {
+		return p
+	}
+	if h.clientPriority {
+		return req.GetContext().GetPriority()
+	}
+	return context.Priority_NORMAL
+}
+
+// 获取各优先级队列的统计信息
+func (h *messageHandle) PriorityStats() []PriorityStats {
+	stats := make([]PriorityStats, priorityLanes)
+	for i := range stats {
+		stats[i].Priority = lanePriority(i)
+		stats[i].Handled = atomic.LoadUint64(&h.handled[i])
+		stats[i].Shed = atomic.LoadUint64(&h.shedCount[i])
+		for _, queue := range h.taskQueues {
+			stats[i].Depth += len(queue[i])
+		}
+	}
+	return stats
+}
+
 // 启动Worker Pool(该动作只能发生一次)
 func (h *messageHandle) StartWorkerPool() {
 	// 根据 h.workerPoolSize 分别开启Worker
 	var i uint32
 	for i = 0; i < h.workerPoolSize; i++ {
 		// 启动一个worker， 阻塞等待消息从channel传递过来
+		h.wg.Add(1)
 		go h.startOneWorker(i)
 	}
 }
 
+// 停止工作池：关闭任务队列，worker将队列中剩余的任务处理完毕后退出
+func (h *messageHandle) StopWorkerPool(ctx gocontext.Context) error {
+	h.lock.Lock()
+	if !h.stopped {
+		h.stopped = true
+		for _, queue := range h.taskQueues {
+			queue.close()
+		}
+	}
+	h.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Debugf("Worker pool is stopped!")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (h *messageHandle) startOneWorker(workerID uint32) {
 	log.Debugf("Worker ID = %d is started!", workerID)
+	defer func() {
+		log.Debugf("Worker ID = %d is exit!", workerID)
+		h.wg.Done()
+	}()
 
-	// 阻塞等待对应消息队列的任务
+	// 阻塞等待对应消息队列的任务，按优先级处理，队列关闭且取空后退出
+	receiver := &taskReceiver{lanes: h.taskQueues[workerID%uint32(len(h.taskQueues))], shed: h.shedExpired}
 	for {
-		select {
-		// 取一个任务就行处理
-		case req := <-h.taskChan:
-			// TODO：根据优先级处理相关信息
-			// log.Infof("Worker ID:%d", workerID)
-			h.HandleRequest(req)
+		req, lane, ok := receiver.receive()
+		if !ok {
+			return
 		}
+		// log.Infof("Worker ID:%d", workerID)
+		h.HandleRequest(req)
+		atomic.AddUint64(&h.handled[lane], 1)
 	}
 }
 
 // 将执行的任务交给工作池处理
 func (h *messageHandle) EntryTaskToWorkerPool(req Request) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if h.stopped {
+		// 工作池已停止，丢弃该请求并回收资源
+		log.Warnf("worker pool is stopped, drop request connID = %d serviceID = %d",
+			req.GetConnection().GetConnID(), req.GetServiceID())
+		globalPool.PutContext(req.GetContext())
+		globalPool.PutRequest(req.(*request))
+		return
+	}
+
+	// 根据 shard 选择worker的任务队列，共享模式下只有一个队列
+	var workerID uint64
+	if h.shard != nil {
+		workerID = h.shard(req) % uint64(len(h.taskQueues))
+	}
 	// log.Debugf("Add ConnID = %d serviceID = %d to workerID = %d", req.GetConnection().GetConnID(), req.GetServiceID(), workerID)
 
-	// 将消息发送给worker的任务队列即可
-	h.taskChan <- req
+	// 将消息发送给worker对应优先级的任务队列即可
+	h.taskQueues[workerID][priorityLane(h.priority(req))] <- req
 }
@@ -0,0 +1,429 @@
+package transport
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"github.com/treeforest/gos/transport/codec"
+	"github.com/treeforest/gos/transport/kcp"
+	"github.com/treeforest/gos/transport/stream"
+	naclbox "golang.org/x/crypto/nacl/box"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Server 的配置项
+type ServerOptions struct {
+	// 服务器名称
+	Name string
+
+	// 服务器绑定的IP版本
+	Network string
+
+	// 服务器监听的IP
+	Host string
+
+	// 服务器监听的端口
+	Port uint32
+
+	// 最大连接数
+	MaxConn uint32
+
+	// 单个IP的最大连接数，为0时不限制
+	MaxConnPerIP uint32
+
+	// 数据包的最大大小
+	MaxPackageSize uint32
+
+	// 封包、拆包模块，为空时根据 MaxPackageSize 创建 Legacy 格式的封包、拆包模块
+	Packer DataPacker
+
+	// 服务器支持的压缩算法，客户端握手时从中协商，为空时不压缩。仅 Versioned 包格式支持压缩
+	Compressions []codec.Compression
+
+	// 数据大小达到该值时才压缩，避免压缩过小的消息
+	CompressThreshold uint32
+
+	// 是否接受客户端的加密握手。仅 Versioned 包格式支持加密
+	Encryption bool
+
+	// 服务器的 box 公钥、私钥，客户端可据此校验服务器身份，为空时启动时随机生成
+	BoxPublicKey  []byte
+	BoxPrivateKey []byte
+
+	// 是否要求所有链接加密，开启后未完成加密握手的链接发送的请求将导致链接被关闭
+	RequireEncryption bool
+
+	// worker工作池大小
+	WorkerPoolSize uint32
+
+	// 有序分发时每个worker对应的消息队列的最大长度
+	MaxWorkerTaskLen uint32
+
+	// 有序分发的函数，为空时所有worker共用一个消息队列
+	Shard ShardFunc
+
+	// 每个链接发送队列的长度
+	SendQueueSize uint32
+
+	// 单个链接同时处理的最大流数，超出时拒绝新的流
+	MaxStreams uint32
+
+	// 每个流的接收窗口(字节)，客户端在窗口耗尽后等待服务端读取
+	StreamWindow uint32
+
+	// 发送队列满时的处理策略
+	SendPolicy SendPolicy
+
+	// SendBlock 策略下等待发送队列空闲的最长时间，为0时一直等待直到链接关闭
+	SendTimeout time.Duration
+
+	// 链接在该时间内未收到完整的数据包则被关闭，为0时不检测
+	ReadIdleTimeout time.Duration
+
+	// 单次写操作的最长时间，超时则关闭链接，为0时不限制
+	WriteTimeout time.Duration
+
+	// 链接在该时间内未收到数据包时，服务器发送 PING 探测，为0时不发送
+	HeartbeatInterval time.Duration
+
+	// 收到退出信号后，优雅关闭服务器的最长等待时间
+	ShutdownTimeout time.Duration
+
+	// 消息处理模块，为空时根据 WorkerPoolSize 创建
+	MsgHandler MessageHandler
+
+	// 链接管理模块，为空时创建新的链接管理器
+	ConnManager ConnManager
+
+	// 处理请求发生 panic 时调用，用于上报告警
+	PanicHandler PanicHandler
+
+	// 是否采用客户端携带的请求优先级，默认忽略，未设置优先级的请求按 NORMAL 处理
+	ClientPriority bool
+
+	// TLS 配置，设置后服务器使用TLS监听
+	TLSConfig *tls.Config
+
+	// TLS 证书、私钥文件，文件被修改后自动重新加载
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// 校验客户端证书的CA文件，设置后开启双向认证
+	TLSClientCAFile string
+
+	// WebSocket 监听的IP、端口与路径，路径为空时不开启 WebSocket 监听
+	WebSocketHost string
+	WebSocketPort uint32
+	WebSocketPath string
+
+	// 校验 WebSocket 请求的 Origin，为空时仅允许同源请求
+	WebSocketCheckOrigin func(r *http.Request) bool
+
+	// 是否开启 KCP(可靠UDP) 监听，以及监听的IP、端口
+	KCPEnable bool
+	KCPHost   string
+	KCPPort   uint32
+
+	// KCP 协议参数
+	KCPConfig kcp.Config
+
+	// 额外的监听器，与内置监听器共享路由与链接管理器
+	Listeners []net.Listener
+}
+
+// 设置 ServerOptions 的函数
+type ServerOption func(o *ServerOptions)
+
+func newServerOptions(opts ...ServerOption) ServerOptions {
+	options := ServerOptions{
+		Name:              "GOS SERVER",
+		Network:           "tcp4",
+		Host:              "0.0.0.0",
+		Port:              9999,
+		MaxConn:           20000,
+		MaxPackageSize:    4096,
+		CompressThreshold: 256,
+		WorkerPoolSize:    20,
+		MaxWorkerTaskLen:  1024,
+		SendQueueSize:     64,
+		MaxStreams:        100,
+		StreamWindow:      stream.DefaultWindow,
+		SendPolicy:        SendBlock,
+		SendTimeout:       time.Second * 5,
+		ShutdownTimeout:   time.Second * 30,
+		KCPConfig:         kcp.DefaultConfig(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Packer == nil {
+		options.Packer = codec.NewLegacyPacker(options.MaxPackageSize)
+	}
+
+	if options.Encryption && len(options.BoxPublicKey) == 0 {
+		publicKey, privateKey, err := naclbox.GenerateKey(rand.Reader)
+		if err != nil {
+			panic(fmt.Errorf("generate box key error: %v", err))
+		}
+		options.BoxPublicKey, options.BoxPrivateKey = publicKey[:], privateKey[:]
+	}
+
+	if options.MsgHandler == nil {
+		if options.Shard != nil {
+			options.MsgHandler = NewOrderedMessageHandler(options.WorkerPoolSize, options.MaxWorkerTaskLen, options.Shard)
+		} else {
+			options.MsgHandler = NewMessageHandler(options.WorkerPoolSize)
+		}
+	}
+
+	if options.PanicHandler != nil {
+		options.MsgHandler.SetPanicHandler(options.PanicHandler)
+	}
+
+	if options.ClientPriority {
+		options.MsgHandler.SetClientPriority(true)
+	}
+
+	if options.ConnManager == nil {
+		options.ConnManager = NewConnManager()
+	}
+
+	return options
+}
+
+// 设置服务器名称
+func WithName(name string) ServerOption {
+	return func(o *ServerOptions) {
+		o.Name = name
+	}
+}
+
+// 设置服务器绑定的IP版本，如 "tcp"、"tcp4"、"tcp6"
+func WithNetwork(network string) ServerOption {
+	return func(o *ServerOptions) {
+		o.Network = network
+	}
+}
+
+// 设置服务器监听的IP与端口
+func WithAddress(host string, port uint32) ServerOption {
+	return func(o *ServerOptions) {
+		o.Host = host
+		o.Port = port
+	}
+}
+
+// 设置最大连接数
+func WithMaxConn(maxConn uint32) ServerOption {
+	return func(o *ServerOptions) {
+		o.MaxConn = maxConn
+	}
+}
+
+// 设置单个IP的最大连接数
+func WithMaxConnPerIP(maxConn uint32) ServerOption {
+	return func(o *ServerOptions) {
+		o.MaxConnPerIP = maxConn
+	}
+}
+
+// 设置数据包的最大大小
+func WithMaxPackageSize(size uint32) ServerOption {
+	return func(o *ServerOptions) {
+		o.MaxPackageSize = size
+	}
+}
+
+// 设置封包、拆包模块，客户端需使用相同的包格式
+func WithPacker(packer DataPacker) ServerOption {
+	return func(o *ServerOptions) {
+		o.Packer = packer
+	}
+}
+
+// 设置服务器支持的压缩算法及压缩阈值，客户端握手时按其偏好从中选择
+func WithCompression(threshold uint32, compressions ...codec.Compression) ServerOption {
+	return func(o *ServerOptions) {
+		o.CompressThreshold = threshold
+		o.Compressions = compressions
+	}
+}
+
+// 开启加密链接，publicKey、privateKey 为服务器的 box 密钥对，为空时随机生成。
+// 客户端握手时交换 box 公钥，由服务器生成每个链接的 secretbox 会话密钥，此后所有数据包均加密并认证
+func WithEncryption(publicKey, privateKey []byte) ServerOption {
+	return func(o *ServerOptions) {
+		o.Encryption = true
+		o.BoxPublicKey = publicKey
+		o.BoxPrivateKey = privateKey
+	}
+}
+
+// 要求所有链接加密
+func WithRequireEncryption() ServerOption {
+	return func(o *ServerOptions) {
+		o.Encryption = true
+		o.RequireEncryption = true
+	}
+}
+
+// 设置worker工作池大小
+func WithWorkerPoolSize(size uint32) ServerOption {
+	return func(o *ServerOptions) {
+		o.WorkerPoolSize = size
+	}
+}
+
+// 设置有序分发时每个worker对应的消息队列的最大长度
+func WithMaxWorkerTaskLen(n uint32) ServerOption {
+	return func(o *ServerOptions) {
+		o.MaxWorkerTaskLen = n
+	}
+}
+
+// 开启有序分发，shard 返回值相同的请求(如同一链接 ShardByConn、同一 session ShardBySession)
+// 由同一个worker按顺序处理。顺序只在 (shard, 优先级) 内保证，不同优先级的请求之间可能乱序，见 ShardFunc
+func WithOrderedDispatch(shard ShardFunc) ServerOption {
+	return func(o *ServerOptions) {
+		o.Shard = shard
+	}
+}
+
+// 设置每个链接发送队列的长度
+func WithSendQueueSize(size uint32) ServerOption {
+	return func(o *ServerOptions) {
+		o.SendQueueSize = size
+	}
+}
+
+// 设置单个链接同时处理的最大流数及每个流的接收窗口(字节)
+func WithStreams(maxStreams, window uint32) ServerOption {
+	return func(o *ServerOptions) {
+		o.MaxStreams = maxStreams
+		o.StreamWindow = window
+	}
+}
+
+// 设置发送队列满时的处理策略，timeout 为 SendBlock 策略下的最长等待时间
+func WithSendPolicy(policy SendPolicy, timeout time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.SendPolicy = policy
+		o.SendTimeout = timeout
+	}
+}
+
+// 设置读空闲超时与单次写操作的超时时间
+func WithIdleTimeout(readIdle, write time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.ReadIdleTimeout = readIdle
+		o.WriteTimeout = write
+	}
+}
+
+// 设置服务器发送 PING 的空闲间隔，应小于 ReadIdleTimeout
+func WithHeartbeat(interval time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.HeartbeatInterval = interval
+	}
+}
+
+// 设置收到退出信号后，优雅关闭服务器的最长等待时间
+func WithShutdownTimeout(d time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.ShutdownTimeout = d
+	}
+}
+
+// 设置服务器的消息处理模块
+func WithMessageHandler(h MessageHandler) ServerOption {
+	return func(o *ServerOptions) {
+		o.MsgHandler = h
+	}
+}
+
+// 设置处理请求发生 panic 时调用的函数
+func WithPanicHandler(f PanicHandler) ServerOption {
+	return func(o *ServerOptions) {
+		o.PanicHandler = f
+	}
+}
+
+// 采用客户端携带的请求优先级。客户端可以借此抢占高优先级队列，仅应对可信的客户端开启；
+// 服务端为服务/方法设置的优先级始终优先
+func WithClientPriority() ServerOption {
+	return func(o *ServerOptions) {
+		o.ClientPriority = true
+	}
+}
+
+// 设置服务器的链接管理模块
+func WithConnManager(m ConnManager) ServerOption {
+	return func(o *ServerOptions) {
+		o.ConnManager = m
+	}
+}
+
+// 设置服务器的TLS配置
+func WithTLSConfig(config *tls.Config) ServerOption {
+	return func(o *ServerOptions) {
+		o.TLSConfig = config
+	}
+}
+
+// 设置服务器的TLS证书、私钥文件，证书文件更新后会在下一次握手时自动重新加载
+func WithTLS(certFile, keyFile string) ServerOption {
+	return func(o *ServerOptions) {
+		o.TLSCertFile = certFile
+		o.TLSKeyFile = keyFile
+	}
+}
+
+// 设置校验客户端证书的CA文件，开启双向认证
+func WithTLSClientCA(caFile string) ServerOption {
+	return func(o *ServerOptions) {
+		o.TLSClientCAFile = caFile
+	}
+}
+
+// 开启 WebSocket 监听，与TCP链接共享路由与链接管理器
+func WithWebSocket(host string, port uint32, path string) ServerOption {
+	return func(o *ServerOptions) {
+		o.WebSocketHost = host
+		o.WebSocketPort = port
+		o.WebSocketPath = path
+	}
+}
+
+// 设置校验 WebSocket 请求 Origin 的函数
+func WithWebSocketCheckOrigin(f func(r *http.Request) bool) ServerOption {
+	return func(o *ServerOptions) {
+		o.WebSocketCheckOrigin = f
+	}
+}
+
+// 开启 KCP(可靠UDP) 监听，适用于对延迟敏感的客户端
+func WithKCP(host string, port uint32) ServerOption {
+	return func(o *ServerOptions) {
+		o.KCPEnable = true
+		o.KCPHost = host
+		o.KCPPort = port
+	}
+}
+
+// 设置 KCP 协议参数
+func WithKCPConfig(config kcp.Config) ServerOption {
+	return func(o *ServerOptions) {
+		o.KCPConfig = config
+	}
+}
+
+// 添加一个已经创建好的监听器，服务器关闭时一同关闭
+func WithListener(listener net.Listener) ServerOption {
+	return func(o *ServerOptions) {
+		o.Listeners = append(o.Listeners, listener)
+	}
+}
@@ -2,35 +2,22 @@ package transport
 
 import (
 	"github.com/treeforest/gos/transport/context"
-	"net"
 	"sync"
 )
 
 /*
  * 全局临时对象池
+ * 链接对象不放入对象池：处理中的请求、分组、会话等在链接停止后仍可能持有其引用
  */
 var globalPool *pool = newPool()
 
 type pool struct {
-	tcpConnPool sync.Pool //TCP 链接套接字临时对象池
-	connPool    sync.Pool //链接临时对象池
 	requestPool sync.Pool //请求临时对象池
 	contextPool sync.Pool //上下文临时对象池
-	messagePool sync.Pool //消息临时对象池
 }
 
 func newPool() *pool {
 	p := new(pool)
-	p.tcpConnPool = sync.Pool{
-		New: func() interface{} {
-			return new(net.TCPConn)
-		},
-	}
-	p.connPool = sync.Pool{
-		New: func() interface{} {
-			return new(connection)
-		},
-	}
 	p.requestPool = sync.Pool{
 		New: func() interface{} {
 			return new(request)
@@ -41,50 +28,25 @@ func newPool() *pool {
 			return new(context.Context)
 		},
 	}
-	p.messagePool = sync.Pool{
-		New: func() interface{} {
-			return new(message)
-		},
-	}
 	return p
 }
 
-func (p *pool) GetTCPConn() *net.TCPConn {
-	return p.tcpConnPool.Get().(*net.TCPConn)
-}
-
-func (p *pool) PutTCPConn(c *net.TCPConn) {
-	p.tcpConnPool.Put(c)
-}
-
-func (p *pool) GetConnection() *connection {
-	return p.connPool.Get().(*connection)
-}
-
-func (p *pool) PutConnection(c *connection) {
-	p.connPool.Put(c)
-}
-
 func (p *pool) GetRequest() *request {
 	return p.requestPool.Get().(*request)
 }
 
 func (p *pool) PutRequest(r *request) {
+	r.release()
 	p.requestPool.Put(r)
 }
 
+// 获取清空后的上下文，避免残留上一个请求的 session、元数据等字段
 func (p *pool) GetContext() *context.Context {
-	return p.contextPool.Get().(*context.Context)
+	c := p.contextPool.Get().(*context.Context)
+	c.Reset()
+	return c
 }
 
 func (p *pool) PutContext(c *context.Context) {
 	p.contextPool.Put(c)
 }
-
-func (p *pool) GetMessage() *message {
-	return p.messagePool.Get().(*message)
-}
-
-func (p *pool) PutMessage(m *message) {
-	p.messagePool.Put(m)
-}
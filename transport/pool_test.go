@@ -0,0 +1,21 @@
+package transport
+
+import "testing"
+
+// 测试从对象池取出的上下文不残留上一个请求的字段
+func TestPoolContextReset(t *testing.T) {
+	for i := 0; i < 8; i++ {
+		ctx := globalPool.GetContext()
+		ctx.Session = "secret"
+		ctx.Metadata = map[string]string{"token": "secret"}
+		ctx.Seq = 1
+		globalPool.PutContext(ctx)
+	}
+
+	for i := 0; i < 8; i++ {
+		ctx := globalPool.GetContext()
+		if ctx.GetSession() != "" || len(ctx.GetMetadata()) != 0 || ctx.GetSeq() != 0 {
+			t.Fatalf("context from pool is not reset: %v", ctx)
+		}
+	}
+}
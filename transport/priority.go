@@ -0,0 +1,169 @@
+package transport
+
+import (
+	"github.com/treeforest/gos/transport/context"
+)
+
+// 优先级队列的数量，下标越小优先级越高
+const priorityLanes = 3
+
+// 低优先级队列非空时，最多连续处理高优先级请求的次数，超过后处理一个低优先级请求，防止饥饿
+const starvationLimit = 16
+
+// 各优先级队列的统计信息
+type PriorityStats struct {
+	// 优先级
+	Priority context.Priority
+
+	// 当前排队等待处理的请求数
+	Depth int
+
+	// 已处理的请求数
+	Handled uint64
+
+	// 出队时已超时或被取消、未经处理即丢弃的请求数
+	Shed uint64
+}
+
+// 优先级对应的队列下标
+func priorityLane(p context.Priority) int {
+	switch p {
+	case context.Priority_HIGH:
+		return 0
+	case context.Priority_LOW:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// 队列下标对应的优先级
+func lanePriority(lane int) context.Priority {
+	switch lane {
+	case 0:
+		return context.Priority_HIGH
+	case 2:
+		return context.Priority_LOW
+	default:
+		return context.Priority_NORMAL
+	}
+}
+
+// 按优先级划分的消息队列
+type taskQueue [priorityLanes]chan Request
+
+func newTaskQueue(size uint32) taskQueue {
+	var q taskQueue
+	for i := range q {
+		q[i] = make(chan Request, size)
+	}
+	return q
+}
+
+func (q taskQueue) close() {
+	for _, lane := range q {
+		close(lane)
+	}
+}
+
+/*
+	worker 从消息队列取任务的状态，每个worker各自持有
+	优先取高优先级的请求；低优先级队列非空且连续被跳过 starvationLimit 次后，优先取该队列的请求
+*/
+type taskReceiver struct {
+	// 消息队列的副本，已关闭且取空的队列置为nil
+	lanes taskQueue
+
+	// 各队列非空时连续被跳过的次数
+	skipped [priorityLanes]int
+
+	// 取出任务后调用，返回 true 时该任务已被丢弃，继续取下一个；为空时不丢弃
+	shed func(req Request, lane int) bool
+}
+
+// 取出下一个需要处理的任务，所有队列都已关闭且取空时返回false
+func (r *taskReceiver) receive() (Request, int, bool) {
+	for {
+		req, lane, ok := r.next()
+		if !ok || r.shed == nil || !r.shed(req, lane) {
+			return req, lane, ok
+		}
+	}
+}
+
+// 按优先级取出下一个任务
+func (r *taskReceiver) next() (Request, int, bool) {
+	// 先处理饥饿的低优先级队列
+	for i := priorityLanes - 1; i > 0; i-- {
+		if r.skipped[i] >= starvationLimit {
+			r.skipped[i] = 0
+			if req, ok := r.tryReceive(i); ok {
+				return req, i, true
+			}
+		}
+	}
+
+	// 按优先级从高到低取
+	for i := 0; i < priorityLanes; i++ {
+		if req, ok := r.tryReceive(i); ok {
+			return req, i, true
+		}
+	}
+
+	// 所有队列都为空，阻塞等待
+	for {
+		if r.lanes[0] == nil && r.lanes[1] == nil && r.lanes[2] == nil {
+			return nil, 0, false
+		}
+
+		var (
+			req Request
+			ok  bool
+			i   int
+		)
+		select {
+		case req, ok = <-r.lanes[0]:
+			i = 0
+		case req, ok = <-r.lanes[1]:
+			i = 1
+		case req, ok = <-r.lanes[2]:
+			i = 2
+		}
+
+		if ok {
+			r.received(i)
+			return req, i, true
+		}
+
+		r.lanes[i] = nil
+	}
+}
+
+// 非阻塞地从第 i 个队列取任务
+func (r *taskReceiver) tryReceive(i int) (Request, bool) {
+	if r.lanes[i] == nil {
+		return nil, false
+	}
+
+	select {
+	case req, ok := <-r.lanes[i]:
+		if !ok {
+			r.lanes[i] = nil
+			return nil, false
+		}
+		r.received(i)
+		return req, true
+	default:
+		return nil, false
+	}
+}
+
+// 从第 i 个队列取到任务后，更新低优先级队列被跳过的次数
+func (r *taskReceiver) received(i int) {
+	r.skipped[i] = 0
+	for j := i + 1; j < priorityLanes; j++ {
+		if r.lanes[j] != nil && len(r.lanes[j]) > 0 {
+			r.skipped[j]++
+		}
+	}
+}
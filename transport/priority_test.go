@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"github.com/treeforest/gos/transport/context"
+	"testing"
+)
+
+func newPriorityRequest(serviceID, methodID uint32, priority context.Priority) *request {
+	return &request{ctx: &context.Context{ServiceId: serviceID, MethodId: methodID, Priority: priority}}
+}
+
+// 测试请求优先级的判定与队列统计
+func TestPriority(t *testing.T) {
+	h := NewMessageHandler(8).(*messageHandle)
+	h.SetServicePriority(1, context.Priority_LOW)
+	h.SetMethodPriority(1, 1, context.Priority_HIGH)
+
+	tests := []struct {
+		req  *request
+		want context.Priority
+	}{
+		{newPriorityRequest(1, 1, context.Priority_LOW), context.Priority_HIGH},
+		{newPriorityRequest(1, 2, context.Priority_HIGH), context.Priority_LOW},
+		// 默认忽略客户端携带的优先级
+		{newPriorityRequest(2, 1, context.Priority_HIGH), context.Priority_NORMAL},
+		{newPriorityRequest(2, 1, context.Priority_LOW), context.Priority_NORMAL},
+	}
+	for _, test := range tests {
+		if p := h.priority(test.req); p != test.want {
+			t.Errorf("serviceID = %d methodID = %d priority = %v, want %v",
+				test.req.GetServiceID(), test.req.GetMethodID(), p, test.want)
+		}
+		h.EntryTaskToWorkerPool(test.req)
+	}
+
+	depth := map[context.Priority]int{}
+	for _, stats := range h.PriorityStats() {
+		depth[stats.Priority] = stats.Depth
+	}
+	if depth[context.Priority_HIGH] != 1 || depth[context.Priority_NORMAL] != 2 || depth[context.Priority_LOW] != 1 {
+		t.Errorf("queue depth = %v", depth)
+	}
+
+	// 开启后未设置优先级的服务采用客户端携带的优先级，服务端的设置依然优先
+	h.SetClientPriority(true)
+	if p := h.priority(newPriorityRequest(2, 1, context.Priority_HIGH)); p != context.Priority_HIGH {
+		t.Errorf("client priority = %v, want %v", p, context.Priority_HIGH)
+	}
+	if p := h.priority(newPriorityRequest(1, 2, context.Priority_HIGH)); p != context.Priority_LOW {
+		t.Errorf("service priority = %v, want %v", p, context.Priority_LOW)
+	}
+}
+
+// 测试按优先级取任务，以及低优先级请求的饥饿保护
+func TestTaskReceiver(t *testing.T) {
+	const highCount = starvationLimit * 2
+
+	q := newTaskQueue(highCount)
+	for i := 0; i < highCount; i++ {
+		q[priorityLane(context.Priority_HIGH)] <- newPriorityRequest(0, 0, context.Priority_HIGH)
+	}
+	q[priorityLane(context.Priority_LOW)] <- newPriorityRequest(0, 0, context.Priority_LOW)
+	q[priorityLane(context.Priority_NORMAL)] <- newPriorityRequest(0, 0, context.Priority_NORMAL)
+	q.close()
+
+	var order []context.Priority
+	r := &taskReceiver{lanes: q}
+	for {
+		req, lane, ok := r.receive()
+		if !ok {
+			break
+		}
+		if p := req.GetContext().GetPriority(); p != lanePriority(lane) {
+			t.Fatalf("request priority = %v received from lane %v", p, lanePriority(lane))
+		}
+		order = append(order, req.GetContext().GetPriority())
+	}
+
+	if len(order) != highCount+2 {
+		t.Fatalf("received %d requests, want %d", len(order), highCount+2)
+	}
+
+	// 连续处理 starvationLimit 个高优先级请求后，依次处理被饥饿的低优先级与普通优先级请求
+	for i, p := range order {
+		want := context.Priority_HIGH
+		switch i {
+		case starvationLimit:
+			want = context.Priority_LOW
+		case starvationLimit + 1:
+			want = context.Priority_NORMAL
+		}
+		if p != want {
+			t.Fatalf("order = %v", order)
+		}
+	}
+}
+
+// 测试 taskReceiver 跳过被丢弃的任务
+func TestTaskReceiverShed(t *testing.T) {
+	q := newTaskQueue(4)
+	q[priorityLane(context.Priority_HIGH)] <- newPriorityRequest(0, 1, context.Priority_HIGH)
+	q[priorityLane(context.Priority_HIGH)] <- newPriorityRequest(0, 2, context.Priority_HIGH)
+	q[priorityLane(context.Priority_LOW)] <- newPriorityRequest(0, 3, context.Priority_LOW)
+	q.close()
+
+	var shed []int
+	r := &taskReceiver{lanes: q, shed: func(req Request, lane int) bool {
+		if req.GetMethodID() == 3 {
+			return false
+		}
+		shed = append(shed, lane)
+		return true
+	}}
+
+	req, lane, ok := r.receive()
+	if !ok || req.GetMethodID() != 3 || lane != priorityLane(context.Priority_LOW) {
+		t.Fatalf("receive = %v, %d, %t", req, lane, ok)
+	}
+	if len(shed) != 2 || shed[0] != 0 || shed[1] != 0 {
+		t.Errorf("shed lanes = %v, want [0 0]", shed)
+	}
+	if _, _, ok := r.receive(); ok {
+		t.Error("receive after all lanes closed")
+	}
+}
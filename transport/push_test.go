@@ -0,0 +1,62 @@
+package transport
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/context"
+	"testing"
+	"time"
+)
+
+// 测试服务器推送与客户端订阅
+func TestPush(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	pushed := make(chan client.Message, 2)
+	c.OnPush(5, 1, func(msg client.Message) {
+		pushed <- msg
+	})
+	c.Dial(s.Addr().String())
+	waitConnNum(t, s, 1)
+
+	var conn Connection
+	s.GetConnManager().Range(func(c Connection) bool {
+		conn = c
+		return false
+	})
+
+	// 已订阅的推送交给处理函数
+	if err := conn.Push(5, 1, &context.Handshake{PublicKey: []byte("match found")}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-pushed:
+		hs := new(context.Handshake)
+		if err := proto.Unmarshal(msg.GetData(), hs); err != nil || string(hs.GetPublicKey()) != "match found" {
+			t.Errorf("push data = %v, %v", hs, err)
+		}
+		if msg.GetContext().GetType() != context.Type_PUSH {
+			t.Errorf("push type = %v, want %v", msg.GetContext().GetType(), context.Type_PUSH)
+		}
+	case <-time.After(time.Second * 3):
+		t.Fatal("recv push timeout")
+	}
+
+	// 广播同样以推送的形式接收
+	s.Broadcast(5, 1, nil)
+	select {
+	case <-pushed:
+	case <-time.After(time.Second * 3):
+		t.Fatal("recv broadcast timeout")
+	}
+
+	// 未订阅的推送通过 Recv 获取
+	conn.Push(5, 2, nil)
+	msg := recvTimeout(c, time.Second*3)
+	if msg == nil || msg.GetMethodID() != 2 || msg.GetContext().GetType() != context.Type_PUSH {
+		t.Errorf("recv unsubscribed push = %v", msg)
+	}
+}
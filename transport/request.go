@@ -1,6 +1,7 @@
 package transport
 
 import (
+	gocontext "context"
 	"github.com/golang/protobuf/proto"
 	"github.com/treeforest/gos/transport/context"
 	"github.com/treeforest/logger"
@@ -12,6 +13,12 @@ type request struct {
 
 	// 请求上下文
 	ctx *context.Context
+
+	// 请求的 Go context，由链接在请求进入工作池前设置
+	goctx gocontext.Context
+
+	// 请求设置了超时或序列号时，用于取消 goctx
+	call *inflightCall
 }
 
 func (r *request) SetRequest(conn Connection, data []byte) (req Request, err error) {
@@ -22,6 +29,8 @@ func (r *request) SetRequest(conn Connection, data []byte) (req Request, err err
 		log.Errorf("proto Unmarshal context error: %v", err)
 		return nil, err
 	}
+	// 响应的元数据只能由服务器设置
+	r.ctx.Trailer = nil
 
 	return r, nil
 }
@@ -47,6 +56,41 @@ func (r *request) GetMethodID() uint32 {
 }
 
 // 获取session
-func (r *request) GetSession() uint32 {
+func (r *request) GetSession() string {
 	return r.ctx.GetSession()
 }
+
+// 获取请求序列号
+func (r *request) GetSeq() uint32 {
+	return r.ctx.GetSeq()
+}
+
+// 获取请求的 Go context
+func (r *request) Context() gocontext.Context {
+	if r.goctx == nil {
+		return gocontext.Background()
+	}
+	return r.goctx
+}
+
+// 释放请求的 Go context，请求回收时调用
+func (r *request) release() {
+	if r.call != nil {
+		r.call.release()
+		r.call = nil
+	}
+	r.goctx = nil
+}
+
+// 获取请求的元数据
+func (r *request) GetMetadata() map[string]string {
+	return r.ctx.GetMetadata()
+}
+
+// 设置响应的元数据
+func (r *request) SetTrailer(key, value string) {
+	if r.ctx.Trailer == nil {
+		r.ctx.Trailer = make(map[string]string)
+	}
+	r.ctx.Trailer[key] = value
+}
@@ -1,26 +1,38 @@
 package transport
 
 import (
+	gocontext "context"
+	"crypto/tls"
 	"fmt"
-	"github.com/treeforest/gos/config"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/kcp"
+	"github.com/treeforest/gos/transport/ws"
 	"github.com/treeforest/logger"
 	"net"
+	"os"
+	"os/signal"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
+// 拒绝链接时，回执错误包(含TLS握手)的最长等待时间
+const rejectTimeout = time.Second
+
+// 同时回执错误包的链接数上限，超出时直接关闭链接
+const maxRejecting = 128
+
 // 定义一个Server服务器模块
 type server struct {
 	// 服务器名称
 	name string
 
-	// 服务器绑定的IP版本
-	tcpVersion string
+	// 服务器配置项
+	opts ServerOptions
 
-	//服务器监听的IP
-	ip string
-
-	// 服务器监听的接口
-	port uint32
+	// 当前server使用的封包、拆包模块
+	packer DataPacker
 
 	// 当前的Server消息管理模块，绑定msgID与对应的业务api关系
 	msgHandler MessageHandler
@@ -28,71 +40,162 @@ type server struct {
 	// 该server的连接管理器
 	connMgr ConnManager
 
+	// 该server的分组管理器
+	groupMgr GroupManager
+
 	// 在Server创建链接之前调用
 	onConnStart func(conn Connection)
 
 	// 在Server销毁链接之后调用
 	onConnStop func(conn Connection)
+
+	// 服务器的监听器(TCP、WebSocket、KCP)
+	listeners []net.Listener
+
+	// 链接ID生成器
+	cid uint32
+
+	// 被拒绝的链接数
+	rejected uint64
+
+	// 正在回执错误包的链接，容量为 maxRejecting
+	rejecting chan struct{}
+
+	// 保护 shutdown 状态
+	lock sync.Mutex
+
+	// 服务器是否已开始关闭
+	shutdown bool
+
+	// 服务器关闭完成后关闭，通知 Serve 返回
+	exitChan chan struct{}
 }
 
 func (s *server) Serve() {
+	// 先订阅退出信号，启动期间收到的信号同样触发优雅关闭
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
 	// 启动server
 	s.Start()
 
 	// TODO 额外业务
 
-	// 阻塞状态
-	select {}
+	// 阻塞，直到收到退出信号或服务器被关闭
+	select {
+	case sig := <-sigChan:
+		log.Infof("server[%s] receive signal %v, shutting down...", s.name, sig)
+		ctx, cancel := gocontext.WithTimeout(gocontext.Background(), s.opts.ShutdownTimeout)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			log.Errorf("shutdown server[%s] error: %v", s.name, err)
+		}
+	case <-s.exitChan:
+	}
 }
 
 func (s *server) Start() {
-	log.Infof("START Server[%s] listener at IP[%s:%d] is starting...", s.name, s.ip, s.port)
-	log.Infof("START Version[%s] MaxConn[%d] MaxPackageSize[%d]",
-		config.ServerConfig.Version, config.ServerConfig.MaxConn, config.ServerConfig.MaxPackageSize)
+	log.Infof("START Server[%s] listener at IP[%s:%d] is starting...", s.name, s.opts.Host, s.opts.Port)
+	log.Infof("START MaxConn[%d] MaxPackageSize[%d] WorkerPoolSize[%d]",
+		s.opts.MaxConn, s.opts.MaxPackageSize, s.opts.WorkerPoolSize)
 
 	// 开启消息队列及工作池(WorkerPool)
 	s.msgHandler.StartWorkerPool()
 
-	go func() {
+	// 配置了TLS，则TCP与WebSocket均使用TLS监听
+	tlsConfig, err := newServerTLSConfig(s.opts)
+	if err != nil {
+		panic(fmt.Errorf("tls config error: %v\n", err))
+	}
+
+	// TCP 监听
+	addr, err := net.ResolveTCPAddr(s.opts.Network, fmt.Sprintf("%s:%d", s.opts.Host, s.opts.Port))
+	if err != nil {
+		panic(fmt.Errorf("resolve tcp addr error: %v\n", err))
+	}
+
+	tcpListener, err := net.ListenTCP(s.opts.Network, addr)
+	if err != nil {
+		panic(fmt.Errorf("listen %s error: %v\n", s.opts.Network, err))
+	}
 
-		addr, err := net.ResolveTCPAddr(s.tcpVersion, fmt.Sprintf("%s:%d", s.ip, s.port))
+	if tlsConfig != nil {
+		s.serveListener(tls.NewListener(tcpListener, tlsConfig))
+		log.Infof("START server[%s] with TLS", s.name)
+	} else {
+		s.serveListener(tcpListener)
+	}
+
+	// WebSocket 监听
+	if s.opts.WebSocketPath != "" {
+		wsListener, err := ws.Listen(s.opts.Network, fmt.Sprintf("%s:%d", s.opts.WebSocketHost, s.opts.WebSocketPort),
+			s.opts.WebSocketPath, tlsConfig, s.opts.WebSocketCheckOrigin)
 		if err != nil {
-			panic(fmt.Errorf("resolve tcp addr error: %v\n", err))
-			return
+			panic(fmt.Errorf("listen websocket error: %v\n", err))
 		}
+		s.serveListener(wsListener)
+		log.Infof("START server[%s] websocket listener at %s%s", s.name, wsListener.Addr(), s.opts.WebSocketPath)
+	}
 
-		listener, err := net.ListenTCP(s.tcpVersion, addr)
+	// KCP 监听
+	if s.opts.KCPEnable {
+		kcpListener, err := kcp.Listen(fmt.Sprintf("%s:%d", s.opts.KCPHost, s.opts.KCPPort), s.opts.KCPConfig)
 		if err != nil {
-			panic(fmt.Errorf("listen %s error: %v\n", s.tcpVersion, err))
-			return
+			panic(fmt.Errorf("listen kcp error: %v\n", err))
 		}
+		s.serveListener(kcpListener)
+		log.Infof("START server[%s] kcp listener at %s", s.name, kcpListener.Addr())
+	}
 
-		log.Infof("START server[%s] success!!!\n", s.name)
-		var cid uint32 = 1 // 连接ID
+	// 额外的监听器
+	for _, listener := range s.opts.Listeners {
+		s.serveListener(listener)
+	}
 
-		for {
-			conn := globalPool.GetTCPConn()
+	// 回收空闲链接
+	s.startReaper()
 
-			conn, err = listener.AcceptTCP()
+	log.Infof("START server[%s] success!!!\n", s.name)
+}
+
+// 在监听器上接受新的链接，不同监听器的链接共享同一个链接管理器与消息处理模块
+func (s *server) serveListener(listener net.Listener) {
+	s.lock.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.lock.Unlock()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
 			if err != nil {
-				globalPool.PutTCPConn(conn)
-				log.Errorf("Accept TCP error: %v", err)
+				if s.isShutdown() {
+					// 监听器已被关闭，停止接受新链接
+					log.Infof("server[%s] stop accepting connections on %s", s.name, listener.Addr())
+					return
+				}
+				log.Errorf("Accept error: %v", err)
 				continue
 			}
 
-			// 判断已经连接的数量，若以达到最大连接数，则直接关闭连接
-			if s.connMgr.Len() >= config.ServerConfig.MaxConn {
-				globalPool.PutTCPConn(conn)
-				log.Warnf("Connection overflow!")
-				//TODO: 回执给客户端超出最大连接的错误包
+			// 判断已经连接的数量，若以达到最大连接数，则回执错误包后关闭连接
+			if s.connMgr.Len() >= s.opts.MaxConn {
+				log.Warnf("Connection overflow! remote addr = %s", conn.RemoteAddr())
+				s.reject(conn, context.Code_ERR_SERVER_FULL)
+				continue
+			}
+
+			// 判断该IP已经连接的数量
+			if s.opts.MaxConnPerIP > 0 && s.connMgr.LenByIP(remoteIP(conn.RemoteAddr())) >= s.opts.MaxConnPerIP {
+				log.Warnf("Too many connections from %s!", conn.RemoteAddr())
+				s.reject(conn, context.Code_ERR_TOO_MANY_CONN)
 				continue
 			}
 
 			// 处理新链接的业务
-			dealConn := NewConnection(s, conn, cid, s.msgHandler)
-			cid++
+			dealConn := NewConnection(s, conn, atomic.AddUint32(&s.cid, 1), s.msgHandler)
 
-			log.Debugf("New connection ConnCount:%d MaxConn:%d ", s.connMgr.Len(), config.ServerConfig.MaxConn)
+			log.Debugf("New connection ConnCount:%d MaxConn:%d ", s.connMgr.Len(), s.opts.MaxConn)
 
 			// 启动当前的链接业务处理
 			go dealConn.Start()
@@ -100,19 +203,188 @@ func (s *server) Start() {
 	}()
 }
 
+// 拒绝链接：在新的goroutine中回执错误包后关闭套接字；
+// 同时回执的链接达到 maxRejecting 时直接关闭，避免大量慢速链接占用goroutine与文件描述符
+func (s *server) reject(conn net.Conn, code context.Code) {
+	atomic.AddUint64(&s.rejected, 1)
+
+	select {
+	case s.rejecting <- struct{}{}:
+	default:
+		conn.Close()
+		return
+	}
+
+	go func() {
+		defer func() { <-s.rejecting }()
+		s.writeReject(conn, code)
+	}()
+}
+
+// 回执错误包后关闭套接字
+func (s *server) writeReject(conn net.Conn, code context.Code) {
+	defer conn.Close()
+
+	data, err := packContext(s.packer, &context.Context{Result: code})
+	if err != nil {
+		log.Errorf("pack reject message error: %v", err)
+		return
+	}
+
+	// TLS链接在首次写入时握手，需要读取客户端数据，读写均需设置截止时间
+	conn.SetDeadline(time.Now().Add(rejectTimeout))
+	if _, err := conn.Write(data); err != nil {
+		log.Warnf("write reject message to %s error: %v", conn.RemoteAddr(), err)
+	}
+}
+
+// 获取被拒绝的链接数
+func (s *server) GetRejectedCount() uint64 {
+	return atomic.LoadUint64(&s.rejected)
+}
+
 func (s *server) Stop() {
+	s.closeListener()
 	s.connMgr.ClearAllConn()
+	s.exit()
 	log.Infof("STOP server[%s]\n", s.name)
 }
 
+func (s *server) Shutdown(ctx gocontext.Context) error {
+	log.Infof("SHUTDOWN server[%s] is shutting down...", s.name)
+
+	// 1、停止接受新的链接
+	s.closeListener()
+
+	// 2、等待工作池中已入队的请求处理完毕
+	err := s.msgHandler.StopWorkerPool(ctx)
+
+	// 3、将各链接待发送的消息写出，并关闭所有链接；ctx 已结束时强制关闭
+	if clearErr := s.connMgr.ClearAllConnContext(ctx); err == nil {
+		err = clearErr
+	}
+
+	s.exit()
+	if err != nil {
+		log.Warnf("SHUTDOWN server[%s] forced: %v", s.name, err)
+		return err
+	}
+	log.Infof("SHUTDOWN server[%s] success", s.name)
+	return nil
+}
+
+// 关闭监听器，停止接受新的链接
+func (s *server) closeListener() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.shutdown {
+		return
+	}
+	s.shutdown = true
+
+	for _, listener := range s.listeners {
+		listener.Close()
+	}
+}
+
+func (s *server) isShutdown() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.shutdown
+}
+
+// 通知 Serve 返回
+func (s *server) exit() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	select {
+	case <-s.exitChan:
+	default:
+		close(s.exitChan)
+	}
+}
+
 func (s *server) RegisterRouter(serviceID uint32, router Router) {
 	s.msgHandler.RegisterRouter(serviceID, router)
 }
 
+func (s *server) RegisterHandler(serviceID, methodID uint32, handler interface{}) {
+	s.msgHandler.RegisterHandler(serviceID, methodID, handler)
+}
+
+func (s *server) RegisterStreamHandler(serviceID, methodID uint32, handler StreamHandler) {
+	s.msgHandler.RegisterStreamHandler(serviceID, methodID, handler)
+}
+
+func (s *server) AddInterceptor(interceptors ...Interceptor) {
+	s.msgHandler.AddInterceptor(interceptors...)
+}
+
+func (s *server) AddServiceInterceptor(serviceID uint32, interceptors ...Interceptor) {
+	s.msgHandler.AddServiceInterceptor(serviceID, interceptors...)
+}
+
+func (s *server) SetServicePriority(serviceID uint32, priority context.Priority) {
+	s.msgHandler.SetServicePriority(serviceID, priority)
+}
+
+func (s *server) SetMethodPriority(serviceID, methodID uint32, priority context.Priority) {
+	s.msgHandler.SetMethodPriority(serviceID, methodID, priority)
+}
+
+func (s *server) PriorityStats() []PriorityStats {
+	return s.msgHandler.PriorityStats()
+}
+
 func (s *server) GetConnManager() ConnManager {
 	return s.connMgr
 }
 
+func (s *server) GetGroupManager() GroupManager {
+	return s.groupMgr
+}
+
+// 向所有链接广播消息
+func (s *server) Broadcast(serviceID, methodID uint32, data []byte) int {
+	var conns []Connection
+	s.connMgr.Range(func(conn Connection) bool {
+		conns = append(conns, conn)
+		return true
+	})
+	return broadcast(conns, serviceID, methodID, data)
+}
+
+func (s *server) Addr() net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.listeners) == 0 {
+		return nil
+	}
+	return s.listeners[0].Addr()
+}
+
+func (s *server) Addrs() []net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	addrs := make([]net.Addr, 0, len(s.listeners))
+	for _, listener := range s.listeners {
+		addrs = append(addrs, listener.Addr())
+	}
+	return addrs
+}
+
+func (s *server) GetDataPacker() DataPacker {
+	return s.packer
+}
+
+func (s *server) GetOptions() ServerOptions {
+	return s.opts
+}
+
 // 设置在Server创建链接之前自动调用的函数
 func (s *server) SetOnConnStartFunc(f func(c Connection)) {
 	s.onConnStart = f
@@ -125,7 +397,7 @@ func (s *server) SetOnConnStopFunc(f func(c Connection)) {
 
 // 在Server创建链接之前调用
 func (s *server) CallOnConnStart(c Connection) {
-	if s.onConnStop != nil {
+	if s.onConnStart != nil {
 		s.onConnStart(c)
 	}
 }
@@ -138,21 +410,19 @@ func (s *server) CallOnConnStop(c Connection) {
 }
 
 /*
-	初始化Server
+	初始化Server，同一进程中可以创建多个相互独立的Server
 */
-var server_once sync.Once
-var global_server *server
-
-func NewServer(serverName string) Server {
-	server_once.Do(func() {
-		global_server = &server{
-			name:       config.ServerConfig.Name,
-			tcpVersion: "tcp4",
-			ip:         config.ServerConfig.Host,
-			port:       config.ServerConfig.TcpPort,
-			msgHandler: NewMessageHandler(),
-			connMgr:    NewConnManager(),
-		}
-	})
-	return global_server
+func NewServer(opts ...ServerOption) Server {
+	options := newServerOptions(opts...)
+
+	return &server{
+		name:       options.Name,
+		opts:       options,
+		packer:     options.Packer,
+		msgHandler: options.MsgHandler,
+		connMgr:    options.ConnManager,
+		groupMgr:   NewGroupManager(),
+		exitChan:   make(chan struct{}),
+		rejecting:  make(chan struct{}, maxRejecting),
+	}
 }
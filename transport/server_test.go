@@ -0,0 +1,147 @@
+package transport
+
+import (
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/codec"
+	"github.com/treeforest/gos/transport/context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 等待服务器的链接数变为 n
+func waitConnNum(t *testing.T, s Server, n uint32) {
+	deadline := time.Now().Add(time.Second * 3)
+	for s.GetConnManager().Len() != n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if num := s.GetConnManager().Len(); num != n {
+		t.Fatalf("conn num = %d, want %d", num, n)
+	}
+}
+
+// 被拒绝的链接应先收到错误码，随后被关闭
+func expectRejected(t *testing.T, addr string, code context.Code) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if ctx := readContext(t, conn); ctx.GetResult() != code {
+		t.Errorf("recv result = %v, want %v", ctx.GetResult(), code)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("rejected connection should be closed, read error: %v", err)
+	}
+}
+
+// 测试超出最大连接数的链接被拒绝
+func TestRejectServerFull(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0), WithMaxConn(1))
+	s.Start()
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitConnNum(t, s, 1)
+
+	expectRejected(t, s.Addr().String(), context.Code_ERR_SERVER_FULL)
+	if n := s.GetRejectedCount(); n != 1 {
+		t.Errorf("rejected count = %d, want 1", n)
+	}
+}
+
+// 测试单个IP的最大连接数
+func TestRejectPerIP(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0), WithMaxConnPerIP(2))
+	s.Start()
+	defer s.Stop()
+
+	var conns []net.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", s.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	waitConnNum(t, s, 2)
+
+	expectRejected(t, s.Addr().String(), context.Code_ERR_TOO_MANY_CONN)
+
+	// 关闭一个链接后，可以重新连接
+	conns[0].Close()
+	waitConnNum(t, s, 1)
+	if n := s.GetConnManager().LenByIP("127.0.0.1"); n != 1 {
+		t.Errorf("conn num of 127.0.0.1 = %d, want 1", n)
+	}
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitConnNum(t, s, 2)
+
+	conns[1].Close()
+	if n := s.GetRejectedCount(); n != 1 {
+		t.Errorf("rejected count = %d, want 1", n)
+	}
+}
+
+// 测试服务器与客户端使用相同的包格式通信
+func TestServerPacker(t *testing.T) {
+	for name, packer := range map[string]DataPacker{
+		"Versioned": codec.NewVersionedPacker(4096),
+		"Varint":    codec.NewVarintPacker(4096),
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := NewServer(WithAddress("127.0.0.1", 0), WithPacker(packer))
+			s.RegisterRouter(1, &echoRouter{})
+			s.Start()
+			defer s.Stop()
+
+			c := client.NewClient(client.WithPacker(packer))
+			c.Dial(s.Addr().String())
+			c.Send(1, 2, []byte("hello"))
+
+			msg := recvTimeout(c, time.Second*3)
+			if msg == nil {
+				t.Fatal("recv message timeout")
+			}
+			if string(msg.GetData()) != "hello" {
+				t.Errorf("recv data = %q, want %q", msg.GetData(), "hello")
+			}
+		})
+	}
+}
+
+// 测试客户端默认限制收到的数据包大小
+func TestClientMaxPackageSize(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0), WithMaxPackageSize(64*1024))
+	s.RegisterRouter(1, &echoRouter{})
+	s.Start()
+	defer s.Stop()
+
+	data := make([]byte, 8*1024)
+
+	// 超过默认大小的数据包导致链接断开
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+	c.Send(1, 2, data)
+	if msg := recvTimeout(c, time.Millisecond*300); msg != nil {
+		t.Errorf("recv %d bytes, want nothing", len(msg.GetData()))
+	}
+
+	c = client.NewClient(client.WithMaxPackageSize(64 * 1024))
+	c.Dial(s.Addr().String())
+	c.Send(1, 2, data)
+	if msg := recvTimeout(c, time.Second*3); msg == nil || len(msg.GetData()) != len(data) {
+		t.Errorf("recv message = %v, want %d bytes", msg, len(data))
+	}
+}
@@ -0,0 +1,90 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// 每创建该数量的会话，清理一次已过期的会话
+const sweepInterval = 1024
+
+type memoryEntry struct {
+	session  Session
+	expireAt time.Time
+}
+
+// 进程内的会话存储，仅适用于单节点部署
+type memoryStore struct {
+	lock     sync.Mutex
+	sessions map[string]*memoryEntry
+	created  int
+}
+
+// 创建进程内的会话存储
+func NewMemoryStore() Store {
+	return &memoryStore{sessions: make(map[string]*memoryEntry)}
+}
+
+func (s *memoryStore) Create(session *Session, ttl time.Duration) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	if e, ok := s.sessions[session.ID]; ok && now.Before(e.expireAt) {
+		return false, nil
+	}
+
+	s.sessions[session.ID] = &memoryEntry{session: *session, expireAt: now.Add(ttl)}
+
+	s.created++
+	if s.created%sweepInterval == 0 {
+		s.sweep(now)
+	}
+	return true, nil
+}
+
+func (s *memoryStore) Get(id string) (*Session, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !time.Now().Before(e.expireAt) {
+		delete(s.sessions, id)
+		return nil, ErrNotFound
+	}
+
+	session := e.session
+	return &session, nil
+}
+
+func (s *memoryStore) Refresh(id string, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	e, ok := s.sessions[id]
+	if !ok || !now.Before(e.expireAt) {
+		return ErrNotFound
+	}
+	e.expireAt = now.Add(ttl)
+	return nil
+}
+
+func (s *memoryStore) Delete(id string) error {
+	s.lock.Lock()
+	delete(s.sessions, id)
+	s.lock.Unlock()
+	return nil
+}
+
+// 清理已过期的会话
+func (s *memoryStore) sweep(now time.Time) {
+	for id, e := range s.sessions {
+		if !now.Before(e.expireAt) {
+			delete(s.sessions, id)
+		}
+	}
+}
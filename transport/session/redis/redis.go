@@ -0,0 +1,83 @@
+// Package redis 使用 utils/dao/cache/redis 实现会话存储，多个网关节点共享会话。
+package redis
+
+import (
+	"encoding/json"
+	"github.com/treeforest/gos/transport/session"
+	cache "github.com/treeforest/gos/utils/dao/cache/redis"
+	"time"
+)
+
+type store struct {
+	op     cache.RedisOp
+	module *cache.Module
+}
+
+// 创建 redis 会话存储，会话以 "module:id" 为key保存，module 为空时使用 "gos:session"
+func NewStore(op cache.RedisOp, module string) session.Store {
+	if module == "" {
+		module = "gos:session"
+	}
+	return &store{op: op, module: cache.NewModule(module)}
+}
+
+func (s *store) key(id string) string {
+	return s.module.Key(id)
+}
+
+func (s *store) Create(sess *session.Session, ttl time.Duration) (bool, error) {
+	value, err := json.Marshal(sess)
+	if err != nil {
+		return false, err
+	}
+
+	// 仅在key不存在时设置，避免覆盖其它节点创建的会话
+	reply, err := s.op.SetExpireNXkey(s.key(sess.ID), string(value), seconds(ttl))
+	if cache.IsRedisNil(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return reply == "OK", nil
+}
+
+func (s *store) Get(id string) (*session.Session, error) {
+	value, err := s.op.GetKey(s.key(id))
+	if cache.IsRedisNil(err) {
+		return nil, session.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sess := new(session.Session)
+	if err := json.Unmarshal([]byte(value), sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+func (s *store) Refresh(id string, ttl time.Duration) error {
+	// EXPIRE 在key不存在时返回0
+	reply, err := s.op.RedisDocmdArgs("EXPIRE", s.key(id), seconds(ttl))
+	if err != nil {
+		return err
+	}
+	if n, ok := reply.(int64); ok && n == 0 {
+		return session.ErrNotFound
+	}
+	return nil
+}
+
+func (s *store) Delete(id string) error {
+	return s.op.DelKey(s.key(id))
+}
+
+// redis 的过期时间以秒为单位，至少为1秒
+func seconds(d time.Duration) int {
+	if n := int(d / time.Second); n > 0 {
+		return n
+	}
+	return 1
+}
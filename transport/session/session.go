@@ -0,0 +1,271 @@
+// Package session 管理 Context.session 字段对应的登录会话。
+//
+// 登录成功后通过 Manager.Create 创建会话并绑定到当前链接，客户端此后在每个请求中携带会话id；
+// 会话id是128位的随机令牌，是请求携带的唯一凭证。通过 Manager.Protect 保护的服务只接受携带有效会话的请求。
+// 会话保存在可替换的 Store 中，多个网关节点共享同一个 Store(如 redis)时，客户端重连到其它节点后会话依然有效。
+//
+// 会话在本节点至多绑定一个存活的链接，推送、组播等按会话查找链接的操作只会发给该链接。
+// 会话已绑定其它存活的链接时，请求不会改变绑定而是被拒绝；需要接管时由应用重新认证后调用 Manager.Resume。
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/logger"
+	"sync"
+	"time"
+)
+
+// 链接属性中保存会话id的key
+const propertyKey = "gos.session"
+
+// 生成会话id时的最大重试次数
+const maxCreateRetry = 8
+
+// 会话id的随机字节数(128位)
+const idBytes = 16
+
+var (
+	// 会话不存在或已过期
+	ErrNotFound = errors.New("session not found")
+
+	// 生成会话id失败
+	ErrCreate = errors.New("session create failed")
+)
+
+// 会话
+type Session struct {
+	// 会话id，即 Context.session
+	ID string
+
+	// 登录的用户标识
+	UserID string
+
+	// 创建时间
+	CreateTime time.Time
+}
+
+/*
+	会话存储
+	过期的会话由存储负责清除，Get 不会返回已过期的会话
+*/
+type Store interface {
+	// 保存会话，id 已存在时返回 false
+	Create(s *Session, ttl time.Duration) (bool, error)
+
+	// 获取会话，不存在或已过期时返回 ErrNotFound
+	Get(id string) (*Session, error)
+
+	// 将会话的有效期重置为 ttl
+	Refresh(id string, ttl time.Duration) error
+
+	// 删除会话
+	Delete(id string) error
+}
+
+/*
+	会话管理模块
+*/
+type Manager struct {
+	// 会话所属的服务器
+	server transport.Server
+
+	// 会话存储
+	store Store
+
+	// 会话的有效期
+	ttl time.Duration
+
+	// 保护 conns
+	lock sync.RWMutex
+
+	// 会话绑定的本节点链接 map[sessionID]connID
+	conns map[string]uint32
+}
+
+// 创建会话管理模块，ttl 为会话的有效期
+func NewManager(server transport.Server, store Store, ttl time.Duration) *Manager {
+	return &Manager{
+		server: server,
+		store:  store,
+		ttl:    ttl,
+		conns:  make(map[string]uint32),
+	}
+}
+
+// 登录成功后创建会话并绑定到 conn
+func (m *Manager) Create(conn transport.Connection, userID string) (*Session, error) {
+	for i := 0; i < maxCreateRetry; i++ {
+		id, err := newID()
+		if err != nil {
+			return nil, err
+		}
+
+		s := &Session{ID: id, UserID: userID, CreateTime: time.Now()}
+		ok, err := m.store.Create(s, m.ttl)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.Bind(conn, id)
+			return s, nil
+		}
+	}
+	return nil, ErrCreate
+}
+
+// 获取会话
+func (m *Manager) Get(id string) (*Session, error) {
+	return m.store.Get(id)
+}
+
+// 延长会话的有效期
+func (m *Manager) Refresh(id string) error {
+	return m.store.Refresh(id, m.ttl)
+}
+
+// 注销会话，并解除与链接的绑定
+func (m *Manager) Destroy(id string) error {
+	m.lock.Lock()
+	delete(m.conns, id)
+	m.lock.Unlock()
+
+	return m.store.Delete(id)
+}
+
+// 将会话绑定到 conn，conn 原先绑定的会话被解除，会话原先绑定的链接不再接收该会话的消息
+func (m *Manager) Bind(conn transport.Connection, id string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.bind(conn, id)
+}
+
+// 调用方需持有 m.lock
+func (m *Manager) bind(conn transport.Connection, id string) {
+	if old, ok := conn.GetProperty(propertyKey); ok && m.conns[old.(string)] == conn.GetConnID() {
+		delete(m.conns, old.(string))
+	}
+	m.conns[id] = conn.GetConnID()
+	conn.SetProperty(propertyKey, id)
+}
+
+// 会话未绑定存活的链接时绑定到 conn，已绑定其它存活的链接时返回 false
+func (m *Manager) bindIfFree(conn transport.Connection, id string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if connID, ok := m.conns[id]; ok && connID != conn.GetConnID() {
+		if _, err := m.server.GetConnManager().Get(connID); err == nil {
+			return false
+		}
+	}
+	m.bind(conn, id)
+	return true
+}
+
+/*
+	将会话接管到 conn
+	会话已绑定其它存活的链接时(如客户端重连而旧链接尚未断开)，请求会被拒绝，
+	应用应在重新认证(如校验用户凭证与 Session.UserID 一致)后调用 Resume 显式接管
+*/
+func (m *Manager) Resume(conn transport.Connection, id string) (*Session, error) {
+	s, err := m.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	m.Bind(conn, id)
+	return s, nil
+}
+
+// 解除 conn 与会话的绑定，会话本身依然有效，可在 OnConnStop 中调用
+func (m *Manager) Unbind(conn transport.Connection) {
+	id, ok := GetSessionID(conn)
+	if !ok {
+		return
+	}
+
+	m.lock.Lock()
+	if m.conns[id] == conn.GetConnID() {
+		delete(m.conns, id)
+	}
+	m.lock.Unlock()
+	conn.RemoveProperty(propertyKey)
+}
+
+// 获取会话在本节点绑定的链接
+func (m *Manager) GetConnection(id string) (transport.Connection, bool) {
+	m.lock.RLock()
+	connID, ok := m.conns[id]
+	m.lock.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	conn, err := m.server.GetConnManager().Get(connID)
+	if err != nil {
+		// 链接已关闭，清除绑定
+		m.lock.Lock()
+		if m.conns[id] == connID {
+			delete(m.conns, id)
+		}
+		m.lock.Unlock()
+		return nil, false
+	}
+	return conn, true
+}
+
+// 获取链接绑定的会话id
+func GetSessionID(conn transport.Connection) (string, bool) {
+	id, ok := conn.GetProperty(propertyKey)
+	if !ok {
+		return "", false
+	}
+	return id.(string), true
+}
+
+// 保护服务：请求必须携带有效的会话，否则回复 ERR_INVALID_SESSION
+func (m *Manager) Protect(serviceIDs ...uint32) {
+	for _, serviceID := range serviceIDs {
+		m.server.AddServiceInterceptor(serviceID, m.intercept)
+	}
+}
+
+/*
+	校验请求携带的会话
+	会话未绑定存活的链接时(如客户端重连后)绑定到请求所在的链接；已绑定其它存活的链接时拒绝请求，不会改变绑定
+*/
+func (m *Manager) intercept(req transport.Request, next transport.Invoker) (proto.Message, error) {
+	id := req.GetSession()
+	if id == "" {
+		return nil, transport.NewError(context.Code_ERR_INVALID_SESSION, "session required")
+	}
+
+	if _, err := m.store.Get(id); err != nil {
+		if err == ErrNotFound {
+			return nil, transport.NewError(context.Code_ERR_INVALID_SESSION, "invalid session")
+		}
+		log.Errorf("get session error: %v", err)
+		return nil, transport.NewError(context.Code_ERR_INTERNAL, "internal error")
+	}
+
+	if bound, ok := GetSessionID(req.GetConnection()); !ok || bound != id {
+		if !m.bindIfFree(req.GetConnection(), id) {
+			return nil, transport.NewError(context.Code_ERR_INVALID_SESSION, "session is bound to another connection")
+		}
+	}
+
+	return next(req)
+}
+
+// 随机生成128位的会话id，以十六进制字符串表示
+func newID() (string, error) {
+	var b [idBytes]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
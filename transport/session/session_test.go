@@ -0,0 +1,185 @@
+package session
+
+import (
+	gocontext "context"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/status"
+	"testing"
+	"time"
+)
+
+// 测试进程内会话存储
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	ok, err := store.Create(&Session{ID: "s1", UserID: "u1"}, time.Millisecond*50)
+	if err != nil || !ok {
+		t.Fatalf("create session = %v, %v", ok, err)
+	}
+	if ok, _ := store.Create(&Session{ID: "s1", UserID: "u2"}, time.Minute); ok {
+		t.Error("create duplicate session should fail")
+	}
+	if s, err := store.Get("s1"); err != nil || s.UserID != "u1" {
+		t.Errorf("get session = %v, %v", s, err)
+	}
+
+	time.Sleep(time.Millisecond * 30)
+	if err := store.Refresh("s1", time.Millisecond*50); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 30)
+	if _, err := store.Get("s1"); err != nil {
+		t.Errorf("refreshed session should be valid, get error: %v", err)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	if _, err := store.Get("s1"); err != ErrNotFound {
+		t.Errorf("get expired session error = %v, want %v", err, ErrNotFound)
+	}
+	if err := store.Refresh("s1", time.Minute); err != ErrNotFound {
+		t.Errorf("refresh expired session error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+// 登录路由：创建会话并将会话id回复给客户端
+type loginRouter struct {
+	transport.BaseRouter
+	m *Manager
+}
+
+func (r *loginRouter) Handle(req transport.Request) {
+	s, err := r.m.Create(req.GetConnection(), string(req.GetContext().GetData()))
+	if err != nil {
+		req.GetContext().Result = context.Code_ERR_HANDLE
+		req.GetConnection().Send(req.GetContext(), nil)
+		return
+	}
+	req.GetConnection().Send(req.GetContext(), []byte(s.ID))
+}
+
+type echoRouter struct {
+	transport.BaseRouter
+}
+
+func (r *echoRouter) Handle(req transport.Request) {
+	req.GetConnection().Send(req.GetContext(), req.GetContext().GetData())
+}
+
+func recvTimeout(t *testing.T, c client.Client) client.Message {
+	deadline := time.Now().Add(time.Second * 3)
+	for time.Now().Before(deadline) {
+		if msg := c.Recv(); msg != nil {
+			return msg
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatal("recv message timeout")
+	return nil
+}
+
+// 测试受保护的服务只接受携带有效会话的请求
+func TestManager(t *testing.T) {
+	s := transport.NewServer(transport.WithAddress("127.0.0.1", 0))
+	m := NewManager(s, NewMemoryStore(), time.Minute)
+	s.RegisterRouter(1, &loginRouter{m: m})
+	s.RegisterRouter(2, &echoRouter{})
+	s.RegisterStreamHandler(2, 2, func(s transport.ServerStream) error {
+		return s.Send(&wrappers.StringValue{Value: "hello"})
+	})
+	m.Protect(2)
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+
+	c.Send(2, 1, []byte("hello"))
+	if msg := recvTimeout(t, c); msg.GetContext().GetResult() != context.Code_ERR_INVALID_SESSION {
+		t.Errorf("request without session result = %v", msg.GetContext().GetResult())
+	}
+
+	c.Send(1, 1, []byte("u1"))
+	id := string(recvTimeout(t, c).GetData())
+	if len(id) != idBytes*2 {
+		t.Errorf("session id %q length = %d, want %d", id, len(id), idBytes*2)
+	}
+	if sess, err := m.Get(id); err != nil || sess.UserID != "u1" {
+		t.Fatalf("get session = %v, %v", sess, err)
+	}
+	loginConn, ok := m.GetConnection(id)
+	if !ok {
+		t.Fatal("session should be bound to the login connection")
+	}
+	if bound, _ := GetSessionID(loginConn); bound != id {
+		t.Errorf("connection session = %q, want %q", bound, id)
+	}
+
+	// 受保护服务的流同样需要有效的会话
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Second*3)
+	defer cancel()
+	openStream := func() error {
+		st, err := c.NewStream(ctx, 2, 2)
+		if err != nil {
+			return err
+		}
+		return st.Recv(new(wrappers.StringValue))
+	}
+	if err := openStream(); status.CodeOf(err) != status.Code(context.Code_ERR_INVALID_SESSION) {
+		t.Errorf("stream without session error = %v, want %s", err, context.Code_ERR_INVALID_SESSION)
+	}
+
+	c.SetSession(id)
+	c.Send(2, 1, []byte("hello"))
+	if msg := recvTimeout(t, c); string(msg.GetData()) != "hello" {
+		t.Errorf("recv %v, want hello", msg.GetContext())
+	}
+	if err := openStream(); err != nil {
+		t.Errorf("stream with session error = %v", err)
+	}
+
+	// 会话已绑定存活的链接时，其它链接携带该会话的请求被拒绝且不改变绑定
+	other := client.NewClient()
+	other.Dial(s.Addr().String())
+	other.SetSession(id)
+	other.Send(2, 1, []byte("hello"))
+	if msg := recvTimeout(t, other); msg.GetContext().GetResult() != context.Code_ERR_INVALID_SESSION {
+		t.Errorf("request from another connection result = %v", msg.GetContext().GetResult())
+	}
+	if conn, ok := m.GetConnection(id); !ok || conn.GetConnID() != loginConn.GetConnID() {
+		t.Error("session should stay bound to the login connection")
+	}
+	c.Send(2, 1, []byte("hello"))
+	if msg := recvTimeout(t, c); string(msg.GetData()) != "hello" {
+		t.Errorf("recv %v, want hello", msg.GetContext())
+	}
+
+	// 注销后会话失效
+	if err := m.Destroy(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.GetConnection(id); ok {
+		t.Error("destroyed session should not be bound")
+	}
+	c.Send(2, 1, []byte("hello"))
+	if msg := recvTimeout(t, c); msg.GetContext().GetResult() != context.Code_ERR_INVALID_SESSION {
+		t.Errorf("request with destroyed session result = %v", msg.GetContext().GetResult())
+	}
+}
+
+// 测试旧版本客户端携带的 uint32 session(字段2)被忽略，不影响请求的解析
+func TestLegacySessionField(t *testing.T) {
+	// session(2) = 42，serviceId(3) = 7
+	data := []byte{0x10, 42, 0x18, 7}
+
+	ctx := new(context.Context)
+	if err := proto.Unmarshal(data, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.GetServiceId() != 7 || ctx.GetSession() != "" {
+		t.Errorf("serviceId = %d session = %q, want 7 and empty session", ctx.GetServiceId(), ctx.GetSession())
+	}
+}
@@ -0,0 +1,115 @@
+package transport
+
+import (
+	gocontext "context"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 在独立的goroutine中运行 Serve，返回 Serve 返回时关闭的 channel
+func serveAsync(t *testing.T, s Server) <-chan struct{} {
+	served := make(chan struct{})
+	go func() {
+		s.Serve()
+		close(served)
+	}()
+
+	deadline := time.Now().Add(time.Second * 3)
+	for s.Addr() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("server is not started")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return served
+}
+
+// 等待 Serve 返回
+func waitServed(t *testing.T, served <-chan struct{}) {
+	select {
+	case <-served:
+	case <-time.After(time.Second * 3):
+		t.Fatal("serve does not return")
+	}
+}
+
+// 测试收到退出信号后优雅关闭：处理中的请求的响应在链接关闭前写出
+func TestGracefulShutdown(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0), WithShutdownTimeout(time.Second*3))
+	s.RegisterHandler(9, 1, func(req Request, in *wrappers.UInt32Value) (*wrappers.UInt32Value, error) {
+		time.Sleep(time.Duration(in.Value) * time.Millisecond)
+		return in, nil
+	})
+	served := serveAsync(t, s)
+	addr := s.Addr().String()
+
+	c := client.NewClient()
+	c.Dial(addr)
+
+	f := c.Go(9, 1, &wrappers.UInt32Value{Value: 300})
+	time.Sleep(time.Millisecond * 100)
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Second*3)
+	defer cancel()
+	out := new(wrappers.UInt32Value)
+	if err := f.Parse(ctx, out); err != nil || out.Value != 300 {
+		t.Errorf("in-flight request = %v, %v, want 300", out, err)
+	}
+	waitServed(t, served)
+
+	// 链接在响应写出后被关闭，且不再接受新的链接
+	if _, err := c.Go(9, 1, &wrappers.UInt32Value{}).Wait(ctx); err != client.ErrClosed {
+		t.Errorf("call after shutdown error = %v, want %v", err, client.ErrClosed)
+	}
+	if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		conn.Close()
+		t.Error("listener should be closed after shutdown")
+	}
+}
+
+// 测试 ctx 结束时 Shutdown 强制关闭不读取数据的链接并返回，Serve 随之返回
+func TestShutdownTimeout(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	served := serveAsync(t, s)
+
+	// 不读取数据的客户端，服务器的写出阻塞在该链接上
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitConnNum(t, s, 1)
+
+	s.GetConnManager().Range(func(c Connection) bool {
+		go func() {
+			data := make([]byte, 1024*1024)
+			for i := 0; i < 64; i++ {
+				if c.Send(&context.Context{ServiceId: 9, MethodId: 1}, data) != nil {
+					return
+				}
+			}
+		}()
+		return true
+	})
+	time.Sleep(time.Millisecond * 200)
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Millisecond*200)
+	defer cancel()
+	start := time.Now()
+	if err := s.Shutdown(ctx); err != gocontext.DeadlineExceeded {
+		t.Errorf("shutdown error = %v, want %v", err, gocontext.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("shutdown took %v after ctx expired", elapsed)
+	}
+	waitServed(t, served)
+	waitConnNum(t, s, 0)
+}
@@ -0,0 +1,196 @@
+// Package status 实现结构化的错误模型。
+//
+// Status 包含错误码、错误描述以及可选的错误详情，序列化后通过 Context.status 返回给客户端。
+// 错误码 [0, 1000) 保留给框架，取值与 context.Code 相同；业务错误码从 1000 开始。
+// 处理函数返回 Err() 得到的错误时，服务器将其完整地回复给客户端，客户端通过 FromError 还原。
+package status
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/treeforest/gos/transport/context"
+)
+
+// 错误码
+type Code uint32
+
+const (
+	// 成功
+	OK Code = 0
+
+	// 业务错误码的起始值，小于该值的错误码保留给框架
+	MinApplicationCode Code = 1000
+)
+
+// 是否为业务错误码
+func (c Code) IsApplication() bool {
+	return c >= MinApplicationCode
+}
+
+func (c Code) String() string {
+	if c.IsApplication() {
+		return fmt.Sprintf("APP_%d", uint32(c))
+	}
+	return context.Code(c).String()
+}
+
+/*
+	结构化的错误信息，创建后不可修改
+*/
+type Status struct {
+	s *context.Status
+}
+
+// 创建错误信息
+func New(code Code, msg string) *Status {
+	return &Status{s: &context.Status{Code: uint32(code), Message: msg}}
+}
+
+// 创建错误信息，错误描述由 format 格式化生成
+func Newf(code Code, format string, a ...interface{}) *Status {
+	return New(code, fmt.Sprintf(format, a...))
+}
+
+// 创建携带错误信息的错误，code 为 OK 时返回 nil
+func Error(code Code, msg string) error {
+	return New(code, msg).Err()
+}
+
+// 创建携带错误信息的错误，错误描述由 format 格式化生成
+func Errorf(code Code, format string, a ...interface{}) error {
+	return New(code, fmt.Sprintf(format, a...)).Err()
+}
+
+// 由框架错误码创建错误信息
+func FromCode(code context.Code, msg string) *Status {
+	return New(Code(code), msg)
+}
+
+// 由 proto 结构创建错误信息，s 为空时表示成功
+func FromProto(s *context.Status) *Status {
+	if s == nil {
+		return New(OK, "")
+	}
+	return &Status{s: proto.Clone(s).(*context.Status)}
+}
+
+/*
+	由响应的上下文还原错误信息
+	服务器未返回 Status 时(如旧版本的服务器)，使用 result 作为错误码
+*/
+func FromContext(ctx *context.Context) *Status {
+	if ctx.GetStatus() != nil {
+		return FromProto(ctx.GetStatus())
+	}
+	return New(Code(ctx.GetResult()), "")
+}
+
+/*
+	获取错误携带的错误信息
+	err 为空时返回 OK；Go context 的超时、取消分别对应 ERR_DEADLINE_EXCEEDED、ERR_CANCELED；
+	err 不携带错误信息时返回 ERR_HANDLE 及 false
+*/
+func FromError(err error) (*Status, bool) {
+	if err == nil {
+		return New(OK, ""), true
+	}
+	var e *statusError
+	if errors.As(err, &e) {
+		return FromProto(e.s), true
+	}
+	if errors.Is(err, gocontext.DeadlineExceeded) {
+		return FromCode(context.Code_ERR_DEADLINE_EXCEEDED, err.Error()), true
+	}
+	if errors.Is(err, gocontext.Canceled) {
+		return FromCode(context.Code_ERR_CANCELED, err.Error()), true
+	}
+	return New(Code(context.Code_ERR_HANDLE), err.Error()), false
+}
+
+// 获取错误携带的错误信息，不携带错误信息时视为业务处理失败
+func Convert(err error) *Status {
+	s, _ := FromError(err)
+	return s
+}
+
+// 获取错误的错误码
+func CodeOf(err error) Code {
+	return Convert(err).Code()
+}
+
+// 错误码
+func (s *Status) Code() Code {
+	return Code(s.s.GetCode())
+}
+
+// 错误描述
+func (s *Status) Message() string {
+	return s.s.GetMessage()
+}
+
+/*
+	框架错误码，用于 Context.result
+	业务错误统一为 ERR_HANDLE，具体的错误码由 Code 获取
+*/
+func (s *Status) ContextCode() context.Code {
+	if s.Code().IsApplication() {
+		return context.Code_ERR_HANDLE
+	}
+	return context.Code(s.Code())
+}
+
+// 返回附加了错误详情的新错误信息
+func (s *Status) WithDetails(details ...proto.Message) (*Status, error) {
+	if s.Code() == OK {
+		return nil, errors.New("status: no error details for OK")
+	}
+
+	p := s.Proto()
+	for _, detail := range details {
+		a, err := ptypes.MarshalAny(detail)
+		if err != nil {
+			return nil, err
+		}
+		p.Details = append(p.Details, a)
+	}
+	return &Status{s: p}, nil
+}
+
+// 错误详情，无法解析的详情以 error 的形式返回
+func (s *Status) Details() []interface{} {
+	details := make([]interface{}, 0, len(s.s.GetDetails()))
+	for _, a := range s.s.GetDetails() {
+		var detail ptypes.DynamicAny
+		if err := ptypes.UnmarshalAny(a, &detail); err != nil {
+			details = append(details, err)
+			continue
+		}
+		details = append(details, detail.Message)
+	}
+	return details
+}
+
+// 转换为 proto 结构，返回值的修改不影响 s
+func (s *Status) Proto() *context.Status {
+	return proto.Clone(s.s).(*context.Status)
+}
+
+// 转换为错误，错误码为 OK 时返回 nil
+func (s *Status) Err() error {
+	if s.Code() == OK {
+		return nil
+	}
+	return &statusError{s: s.Proto()}
+}
+
+// 携带错误信息的错误
+type statusError struct {
+	s *context.Status
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("code = %s, msg = %s", Code(e.s.GetCode()), e.s.GetMessage())
+}
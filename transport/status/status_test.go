@@ -0,0 +1,64 @@
+package status
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/treeforest/gos/transport/context"
+	"testing"
+)
+
+// 测试错误与错误信息的相互转换
+func TestStatus(t *testing.T) {
+	s, err := New(1001, "balance not enough").WithDetails(&wrappers.Int64Value{Value: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 经过 proto 序列化后依然保持错误码、描述与详情
+	data, err := proto.Marshal(&context.Context{Result: s.ContextCode(), Status: s.Proto()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := new(context.Context)
+	if err := proto.Unmarshal(data, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.GetResult() != context.Code_ERR_HANDLE {
+		t.Errorf("result = %s, want %s", ctx.GetResult(), context.Code_ERR_HANDLE)
+	}
+
+	// 包装后的错误依然可以还原
+	wrapped := fmt.Errorf("call: %w", FromContext(ctx).Err())
+	got, ok := FromError(wrapped)
+	if !ok || got.Code() != 1001 || got.Message() != "balance not enough" {
+		t.Fatalf("FromError = %v, %v", got.Proto(), ok)
+	}
+	details := got.Details()
+	if len(details) != 1 {
+		t.Fatalf("details = %v", details)
+	}
+	if v, ok := details[0].(*wrappers.Int64Value); !ok || v.Value != 42 {
+		t.Errorf("detail = %v", details[0])
+	}
+
+	for _, tc := range []struct {
+		err  error
+		code Code
+		ok   bool
+	}{
+		{nil, OK, true},
+		{Error(OK, ""), OK, true},
+		{Error(Code(context.Code_ERR_INTERNAL), "internal"), Code(context.Code_ERR_INTERNAL), true},
+		{fmt.Errorf("plain error"), Code(context.Code_ERR_HANDLE), false},
+	} {
+		if s, ok := FromError(tc.err); s.Code() != tc.code || ok != tc.ok {
+			t.Errorf("FromError(%v) = %s, %v, want %s, %v", tc.err, s.Code(), ok, tc.code, tc.ok)
+		}
+	}
+
+	// 旧版本的服务器只返回 result
+	if code := FromContext(&context.Context{Result: context.Code_ERR_SERVICE_NOT_FOUND}).Code(); code != Code(context.Code_ERR_SERVICE_NOT_FOUND) {
+		t.Errorf("code = %s, want %s", code, context.Code_ERR_SERVICE_NOT_FOUND)
+	}
+}
@@ -0,0 +1,215 @@
+package transport
+
+import (
+	gocontext "context"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/stream"
+	"github.com/treeforest/logger"
+	"runtime/debug"
+	"time"
+)
+
+// 流式处理函数，可实现服务端流、客户端流与双向流。处理函数返回时流结束
+type StreamHandler func(s ServerStream) error
+
+/*
+	服务端的流
+	Send 与 Recv 可以在不同的goroutine中调用
+*/
+type ServerStream interface {
+	// 流的 Go context，客户端中止流、超过客户端设置的超时时间或链接断开时被取消
+	Context() gocontext.Context
+
+	// 得到当前链接
+	GetConnection() Connection
+
+	// 获取服务ID
+	GetServiceID() uint32
+
+	// 获取方法ID
+	GetMethodID() uint32
+
+	// 获取客户端打开流时携带的元数据
+	GetMetadata() map[string]string
+
+	// 设置流结束时回复给客户端的元数据(trailer)
+	SetTrailer(key, value string)
+
+	// 发送一条消息，客户端的接收窗口耗尽时阻塞
+	Send(msg proto.Message) error
+
+	// 接收一条消息，客户端结束发送后返回 io.EOF
+	Recv(msg proto.Message) error
+}
+
+type serverStream struct {
+	conn *connection
+	st   *stream.Stream
+
+	// 客户端打开流的请求，交给拦截器；流结束时回复给客户端的元数据(trailer)也记录在其中，
+	// 仅由处理函数所在的goroutine访问
+	req *request
+}
+
+func (s *serverStream) Context() gocontext.Context {
+	return s.st.Context()
+}
+
+func (s *serverStream) GetConnection() Connection {
+	return s.conn
+}
+
+func (s *serverStream) GetServiceID() uint32 {
+	return s.req.GetServiceID()
+}
+
+func (s *serverStream) GetMethodID() uint32 {
+	return s.req.GetMethodID()
+}
+
+func (s *serverStream) GetMetadata() map[string]string {
+	return s.req.GetMetadata()
+}
+
+func (s *serverStream) SetTrailer(key, value string) {
+	s.req.SetTrailer(key, value)
+}
+
+func (s *serverStream) Send(msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return s.st.Send(data)
+}
+
+func (s *serverStream) Recv(msg proto.Message) error {
+	data, err := s.st.Recv()
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// 发送流消息
+func (c *connection) sendStreamFrame(ctx *context.Context) error {
+	return c.Send(ctx, ctx.Data)
+}
+
+// 拒绝客户端打开的流，返回码通过 STREAM_END 回复
+func (c *connection) refuseStream(open *context.Context, err error) {
+	end := &context.Context{Type: context.Type_STREAM_END, StreamId: open.GetStreamId(),
+		ServiceId: open.GetServiceId(), MethodId: open.GetMethodId()}
+	setResult(end, err)
+	c.sendStreamFrame(end)
+}
+
+/*
+	处理客户端打开的流
+	告知客户端服务端的接收窗口后，在独立的goroutine中执行流式处理函数。open 不再回收
+*/
+func (c *connection) openStream(open *context.Context) {
+	if open.GetStreamId() == 0 {
+		log.Warnf("connID = %d open stream without stream id", c.connID)
+		return
+	}
+	if _, ok := c.streams.Get(open.GetStreamId()); ok {
+		log.Warnf("connID = %d stream id = %d is already open", c.connID, open.GetStreamId())
+		return
+	}
+
+	handler, err := c.msgHandler.GetStreamHandler(open.GetServiceId(), open.GetMethodId())
+	if err != nil {
+		log.Warnf("connID = %d open stream serviceID = %d methodID = %d error: %v",
+			c.connID, open.GetServiceId(), open.GetMethodId(), err)
+		c.refuseStream(open, err)
+		return
+	}
+
+	opts := c.server.GetOptions()
+	if opts.MaxStreams > 0 && uint32(c.streams.Len()) >= opts.MaxStreams {
+		c.refuseStream(open, NewError(context.Code_ERR_STREAM_REFUSED, "too many streams"))
+		return
+	}
+
+	parent, release := c.ctx, gocontext.CancelFunc(func() {})
+	if timeout := open.GetTimeout(); timeout > 0 {
+		parent, release = gocontext.WithTimeout(c.ctx, time.Duration(timeout)*time.Millisecond)
+	}
+	st := stream.New(parent, open.GetStreamId(), open.GetServiceId(), open.GetMethodId(),
+		opts.StreamWindow, open.GetWindow(), c.sendStreamFrame)
+	c.streams.Add(st)
+
+	window := &context.Context{Type: context.Type_STREAM_WINDOW, StreamId: st.ID(),
+		ServiceId: open.GetServiceId(), MethodId: open.GetMethodId(), Window: opts.StreamWindow}
+	c.sendStreamFrame(window)
+
+	// 打开流的请求不来自对象池，不回收
+	req := &request{conn: c, ctx: open, goctx: st.Context()}
+	c.streamWg.Add(1)
+	go c.runStream(&serverStream{conn: c, st: st, req: req}, handler, release)
+}
+
+// 执行流式处理函数，结束后将返回码与 trailer 回复给客户端
+func (c *connection) runStream(s *serverStream, handler StreamHandler, release gocontext.CancelFunc) {
+	defer c.streamWg.Done()
+	defer release()
+
+	err := c.callStreamHandler(s, handler)
+	if err != nil {
+		log.Warnf("stream serviceID = %d methodID = %d error: %v", s.GetServiceID(), s.GetMethodID(), err)
+	}
+
+	c.streams.Remove(s.st)
+
+	// 流已被中止时不再回复
+	end := &context.Context{Trailer: s.req.GetContext().GetTrailer()}
+	setResult(end, err)
+	s.st.CloseSend(end)
+	s.st.Release()
+}
+
+// 经过拦截器执行流式处理函数，panic 视为服务器内部错误
+func (c *connection) callStreamHandler(s *serverStream, handler StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("stream handler panic connID = %d serviceID = %d methodID = %d: %v\n%s",
+				c.connID, s.GetServiceID(), s.GetMethodID(), r, debug.Stack())
+			err = NewError(context.Code_ERR_INTERNAL, "internal error")
+		}
+	}()
+	return c.msgHandler.HandleStream(s.req, func() error {
+		return handler(s)
+	})
+}
+
+// 将客户端发来的流消息交给对应的流，已结束的流的消息被忽略
+func (c *connection) dispatchStream(ctx *context.Context) {
+	st, ok := c.streams.Get(ctx.GetStreamId())
+	if !ok {
+		return
+	}
+
+	switch ctx.GetType() {
+	case context.Type_STREAM_DATA:
+		if err := st.OnData(ctx.GetData()); err != nil {
+			log.Warnf("connID = %d stream id = %d error: %v", c.connID, st.ID(), err)
+			c.streams.Remove(st)
+			st.Reset(context.Code_ERR_FLOW_CONTROL, err)
+		}
+	case context.Type_STREAM_END:
+		st.OnEnd(nil, false)
+	case context.Type_STREAM_RESET:
+		c.streams.Remove(st)
+		st.Abort(stream.ResetError(ctx.GetResult()))
+	case context.Type_STREAM_WINDOW:
+		st.OnWindow(ctx.GetWindow())
+	}
+}
+
+// 链接断开，中止所有的流并等待流式处理函数返回
+func (c *connection) closeStreams() {
+	c.streams.AbortAll(ErrConnClosed)
+	c.streamWg.Wait()
+}
@@ -0,0 +1,331 @@
+// Package stream 实现流式调用的多路复用与流量控制，由服务端(transport)与客户端(client)共用。
+//
+// 流由客户端通过 STREAM_OPEN 打开，双方在同一个链接上以 streamId 区分不同的流。
+// 每个流的双方各自维护接收窗口，对方只有在发送窗口大于0时才能发送消息；接收方的应用读取消息后
+// 通过 STREAM_WINDOW 归还窗口。为避免大于窗口的消息无法发送，发送窗口大于0时即可发送一条完整的消息。
+//
+// 客户端发送 STREAM_END 表示不再发送(半关闭)，仍可继续接收；服务端发送 STREAM_END 表示整个流结束。
+// 任何一方都可以通过 STREAM_RESET 中止流。
+package stream
+
+import (
+	gocontext "context"
+	"errors"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/status"
+	"io"
+	"sync"
+)
+
+// 默认的接收窗口(字节)
+const DefaultWindow = 64 * 1024
+
+var (
+	// 本端已结束发送
+	ErrSendClosed = errors.New("stream: send on closed stream")
+
+	// 对方发送的数据超出了接收窗口
+	ErrFlowControl = errors.New("stream: flow control window exceeded")
+)
+
+// 发送流消息的函数，由链接实现
+type SendFunc func(ctx *context.Context) error
+
+/*
+	流
+	Send 与 Recv 可以在不同的goroutine中调用；On 开头的方法由链接的读goroutine调用，不会阻塞
+*/
+type Stream struct {
+	id        uint32
+	serviceID uint32
+	methodID  uint32
+
+	// 流的 Go context，流被中止或释放时取消
+	ctx    gocontext.Context
+	cancel gocontext.CancelFunc
+
+	send SendFunc
+
+	// 本端的接收窗口
+	recvWindow uint32
+
+	// 保护以下字段
+	lock sync.Mutex
+
+	// 状态变化时关闭并替换，唤醒等待的 Send、Recv
+	notify chan struct{}
+
+	// 发送窗口，对方归还窗口前可能为负数
+	sendWindow int64
+
+	// 本端已结束发送
+	sendClosed bool
+
+	// 对方已结束整个流，此后 Send 返回 io.EOF
+	finished bool
+
+	// 已收到、尚未读取的消息
+	recvQueue [][]byte
+
+	// 对方已结束发送，读完 recvQueue 后 Recv 返回 io.EOF
+	recvClosed bool
+
+	// 已收到、尚未归还窗口的字节数
+	inflight int64
+
+	// 已读取、尚未归还窗口的字节数
+	unacked int64
+
+	// 对方的 STREAM_END 消息
+	end *context.Context
+
+	// 流被中止的原因
+	err error
+}
+
+// 创建流，recvWindow 为本端的接收窗口，sendWindow 为对方告知的接收窗口
+func New(parent gocontext.Context, id, serviceID, methodID, recvWindow, sendWindow uint32, send SendFunc) *Stream {
+	s := &Stream{
+		id:         id,
+		serviceID:  serviceID,
+		methodID:   methodID,
+		send:       send,
+		recvWindow: recvWindow,
+		notify:     make(chan struct{}),
+		sendWindow: int64(sendWindow),
+	}
+	s.ctx, s.cancel = gocontext.WithCancel(parent)
+	return s
+}
+
+// 流id
+func (s *Stream) ID() uint32 {
+	return s.id
+}
+
+// 流的 Go context
+func (s *Stream) Context() gocontext.Context {
+	return s.ctx
+}
+
+// 流的消息
+func (s *Stream) frame(t context.Type) *context.Context {
+	return &context.Context{Type: t, StreamId: s.id, ServiceId: s.serviceID, MethodId: s.methodID}
+}
+
+// 唤醒等待的 Send、Recv，调用方需持有 s.lock
+func (s *Stream) broadcast() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// 等待状态变化，返回 false 表示流的 Go context 已取消。调用方需持有 s.lock，返回时依然持有
+func (s *Stream) wait() bool {
+	notify := s.notify
+	s.lock.Unlock()
+	defer s.lock.Lock()
+
+	select {
+	case <-notify:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// 中止的原因，未被中止时为 Go context 的错误。调用方需持有 s.lock
+func (s *Stream) abortErr() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.ctx.Err()
+}
+
+// 发送一条消息，发送窗口耗尽时阻塞等待对方归还窗口
+func (s *Stream) Send(data []byte) error {
+	s.lock.Lock()
+	for {
+		if s.err != nil {
+			err := s.err
+			s.lock.Unlock()
+			return err
+		}
+		if s.sendClosed {
+			s.lock.Unlock()
+			return ErrSendClosed
+		}
+		if s.finished {
+			s.lock.Unlock()
+			return io.EOF
+		}
+		if s.sendWindow > 0 {
+			break
+		}
+		if !s.wait() {
+			err := s.abortErr()
+			s.lock.Unlock()
+			return err
+		}
+	}
+	s.sendWindow -= int64(len(data))
+	s.lock.Unlock()
+
+	ctx := s.frame(context.Type_STREAM_DATA)
+	ctx.Data = data
+	return s.send(ctx)
+}
+
+// 结束发送，end 可携带返回码与 trailer，重复调用时直接返回
+func (s *Stream) CloseSend(end *context.Context) error {
+	s.lock.Lock()
+	if s.err != nil {
+		err := s.err
+		s.lock.Unlock()
+		return err
+	}
+	if s.sendClosed || s.finished {
+		s.lock.Unlock()
+		return nil
+	}
+	s.sendClosed = true
+	s.broadcast()
+	s.lock.Unlock()
+
+	if end == nil {
+		end = new(context.Context)
+	}
+	end.Type = context.Type_STREAM_END
+	end.StreamId = s.id
+	end.ServiceId = s.serviceID
+	end.MethodId = s.methodID
+	return s.send(end)
+}
+
+// 读取一条消息，对方结束发送且消息均已读取时返回 io.EOF
+func (s *Stream) Recv() ([]byte, error) {
+	s.lock.Lock()
+	for len(s.recvQueue) == 0 {
+		if s.err != nil {
+			err := s.err
+			s.lock.Unlock()
+			return nil, err
+		}
+		if s.recvClosed {
+			s.lock.Unlock()
+			return nil, io.EOF
+		}
+		if !s.wait() {
+			err := s.abortErr()
+			s.lock.Unlock()
+			return nil, err
+		}
+	}
+	if s.err != nil {
+		err := s.err
+		s.lock.Unlock()
+		return nil, err
+	}
+
+	data := s.recvQueue[0]
+	s.recvQueue[0] = nil
+	s.recvQueue = s.recvQueue[1:]
+
+	// 读取的数据达到接收窗口的一半时归还窗口
+	var increment int64
+	s.unacked += int64(len(data))
+	if s.unacked >= int64(s.recvWindow/2) && !s.recvClosed {
+		increment = s.unacked
+		s.unacked = 0
+		s.inflight -= increment
+	}
+	s.lock.Unlock()
+
+	if increment > 0 {
+		ctx := s.frame(context.Type_STREAM_WINDOW)
+		ctx.Window = uint32(increment)
+		s.send(ctx)
+	}
+	return data, nil
+}
+
+// 对方的 STREAM_END 消息，对方未结束时返回 nil
+func (s *Stream) End() *context.Context {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.end
+}
+
+// 收到对方的消息，超出接收窗口时返回 ErrFlowControl
+func (s *Stream) OnData(data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil || s.recvClosed {
+		return nil
+	}
+	if s.inflight >= int64(s.recvWindow) {
+		return ErrFlowControl
+	}
+	s.inflight += int64(len(data))
+	s.recvQueue = append(s.recvQueue, data)
+	s.broadcast()
+	return nil
+}
+
+// 对方结束发送，final 为 true 时表示对方结束了整个流
+func (s *Stream) OnEnd(end *context.Context, final bool) {
+	s.lock.Lock()
+	s.recvClosed = true
+	s.end = end
+	if final {
+		s.finished = true
+	}
+	s.broadcast()
+	s.lock.Unlock()
+}
+
+// 对方归还窗口
+func (s *Stream) OnWindow(increment uint32) {
+	s.lock.Lock()
+	s.sendWindow += int64(increment)
+	s.broadcast()
+	s.lock.Unlock()
+}
+
+// 中止流，此后 Send、Recv 返回 err
+func (s *Stream) Abort(err error) {
+	s.lock.Lock()
+	if s.err == nil {
+		s.err = err
+		s.broadcast()
+	}
+	s.lock.Unlock()
+	s.cancel()
+}
+
+// 通知对方中止流，并在本端以 err 中止
+func (s *Stream) Reset(code context.Code, err error) {
+	s.lock.Lock()
+	aborted := s.err != nil || s.finished
+	s.lock.Unlock()
+
+	if !aborted {
+		ctx := s.frame(context.Type_STREAM_RESET)
+		ctx.Result = code
+		s.send(ctx)
+	}
+	s.Abort(err)
+}
+
+// 流已结束，释放流的 Go context，已收到的消息依然可以读取
+func (s *Stream) Release() {
+	s.cancel()
+}
+
+// 对方中止流时 Send、Recv 返回的错误
+func ResetError(code context.Code) error {
+	if code == context.Code_SUCCESS {
+		code = context.Code_ERR_CANCELED
+	}
+	return status.FromCode(code, "stream reset by peer").Err()
+}
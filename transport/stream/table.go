@@ -0,0 +1,64 @@
+package stream
+
+import "sync"
+
+// 链接上处理中的流
+type Table struct {
+	lock    sync.Mutex
+	streams map[uint32]*Stream
+}
+
+func NewTable() *Table {
+	return &Table{streams: make(map[uint32]*Stream)}
+}
+
+// 添加流，流id已存在时返回 false
+func (t *Table) Add(s *Stream) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.streams[s.id]; ok {
+		return false
+	}
+	t.streams[s.id] = s
+	return true
+}
+
+// 获取流
+func (t *Table) Get(id uint32) (*Stream, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	s, ok := t.streams[id]
+	return s, ok
+}
+
+// 移除流，返回 false 表示流已被移除
+func (t *Table) Remove(s *Stream) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.streams[s.id] != s {
+		return false
+	}
+	delete(t.streams, s.id)
+	return true
+}
+
+// 处理中的流的数量
+func (t *Table) Len() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.streams)
+}
+
+// 移除并以 err 中止所有的流
+func (t *Table) AbortAll(err error) {
+	t.lock.Lock()
+	streams := t.streams
+	t.streams = make(map[uint32]*Stream)
+	t.lock.Unlock()
+
+	for _, s := range streams {
+		s.Abort(err)
+	}
+}
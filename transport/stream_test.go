@@ -0,0 +1,253 @@
+package transport
+
+import (
+	gocontext "context"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/context"
+	"github.com/treeforest/gos/transport/status"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试服务端流、客户端流与双向流复用同一个链接
+func TestStream(t *testing.T) {
+	var sent int32
+	s := NewServer(WithAddress("127.0.0.1", 0), WithStreams(10, 8*1024))
+	// 服务端流：发送 n 条 1KB 的消息
+	s.RegisterStreamHandler(9, 1, func(s ServerStream) error {
+		in := new(wrappers.UInt32Value)
+		if err := s.Recv(in); err != nil {
+			return err
+		}
+		for i := uint32(0); i < in.Value; i++ {
+			if err := s.Send(&wrappers.BytesValue{Value: make([]byte, 1024)}); err != nil {
+				return err
+			}
+			atomic.AddInt32(&sent, 1)
+		}
+		s.SetTrailer("count", "done")
+		return nil
+	})
+	// 客户端流：返回收到的数之和
+	s.RegisterStreamHandler(9, 2, func(s ServerStream) error {
+		var sum uint32
+		for {
+			in := new(wrappers.UInt32Value)
+			if err := s.Recv(in); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			sum += in.Value
+		}
+		return s.Send(&wrappers.UInt32Value{Value: sum})
+	})
+	// 双向流：原样返回
+	s.RegisterStreamHandler(9, 3, func(s ServerStream) error {
+		for {
+			in := new(wrappers.StringValue)
+			if err := s.Recv(in); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := s.Send(in); err != nil {
+				return err
+			}
+		}
+	})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient(client.WithStreamWindow(8 * 1024))
+	c.Dial(s.Addr().String())
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Second*5)
+	defer cancel()
+
+	// 服务端流：客户端不读取时服务端受接收窗口限制
+	const n = 32
+	down, err := c.NewStream(ctx, 9, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := down.Send(&wrappers.UInt32Value{Value: n}); err != nil {
+		t.Fatal(err)
+	}
+	down.CloseSend()
+	time.Sleep(time.Millisecond * 300)
+	if v := atomic.LoadInt32(&sent); v > 8 {
+		t.Errorf("sent %d messages before client reads, want at most 8", v)
+	}
+	for i := 0; i < n; i++ {
+		out := new(wrappers.BytesValue)
+		if err := down.Recv(out); err != nil {
+			t.Fatalf("recv message %d error: %v", i, err)
+		}
+	}
+	if err := down.Recv(new(wrappers.BytesValue)); err != io.EOF {
+		t.Errorf("recv after end = %v, want %v", err, io.EOF)
+	}
+	if v := down.Trailer()["count"]; v != "done" {
+		t.Errorf("trailer count = %q, want %q", v, "done")
+	}
+
+	// 客户端流与双向流同时进行
+	up, err := c.NewStream(ctx, 9, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo, err := c.NewStream(ctx, 9, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(1); i <= 10; i++ {
+		if err := up.Send(&wrappers.UInt32Value{Value: i}); err != nil {
+			t.Fatal(err)
+		}
+		if err := echo.Send(&wrappers.StringValue{Value: "hello"}); err != nil {
+			t.Fatal(err)
+		}
+		out := new(wrappers.StringValue)
+		if err := echo.Recv(out); err != nil || out.Value != "hello" {
+			t.Fatalf("echo recv = %v, %v", out, err)
+		}
+	}
+	up.CloseSend()
+	echo.CloseSend()
+
+	sum := new(wrappers.UInt32Value)
+	if err := up.Recv(sum); err != nil || sum.Value != 55 {
+		t.Errorf("sum = %v, %v, want 55", sum, err)
+	}
+	if err := up.Recv(sum); err != io.EOF {
+		t.Errorf("recv after end = %v, want %v", err, io.EOF)
+	}
+	if err := echo.Recv(new(wrappers.StringValue)); err != io.EOF {
+		t.Errorf("echo recv after close send = %v, want %v", err, io.EOF)
+	}
+
+	// 未注册的流返回错误码
+	unknown, err := c.NewStream(ctx, 9, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = unknown.Recv(new(wrappers.StringValue))
+	if code := status.CodeOf(err); code != status.Code(context.Code_ERR_METHOD_NOT_FOUND) {
+		t.Errorf("unknown stream error = %v, want %s", err, context.Code_ERR_METHOD_NOT_FOUND)
+	}
+}
+
+// 测试客户端中止流与链接断开时服务端流的 Go context 被取消
+func TestStreamCancel(t *testing.T) {
+	done := make(chan error, 1)
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	s.RegisterStreamHandler(9, 1, func(s ServerStream) error {
+		<-s.Context().Done()
+		done <- s.Context().Err()
+		return s.Context().Err()
+	})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+
+	wait := func() {
+		select {
+		case err := <-done:
+			if err != gocontext.Canceled {
+				t.Errorf("stream context error = %v, want %v", err, gocontext.Canceled)
+			}
+		case <-time.After(time.Second * 3):
+			t.Fatal("stream context is not done")
+		}
+	}
+
+	// 客户端中止流
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	st, err := c.NewStream(ctx, 9, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 100)
+	cancel()
+	if err := st.Recv(new(wrappers.StringValue)); err != gocontext.Canceled {
+		t.Errorf("recv error = %v, want %v", err, gocontext.Canceled)
+	}
+	wait()
+
+	// 链接断开
+	st, err = c.NewStream(gocontext.Background(), 9, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 100)
+	s.GetConnManager().ClearAllConn()
+	wait()
+	if err := st.Recv(new(wrappers.StringValue)); err != client.ErrClosed {
+		t.Errorf("recv error = %v, want %v", err, client.ErrClosed)
+	}
+}
+
+// 测试打开流时经过全局拦截器与服务级拦截器
+func TestStreamInterceptor(t *testing.T) {
+	var opened int32
+	s := NewServer(WithAddress("127.0.0.1", 0))
+	s.AddInterceptor(func(req Request, next Invoker) (proto.Message, error) {
+		if req.GetContext().GetType() == context.Type_STREAM_OPEN {
+			atomic.AddInt32(&opened, 1)
+		}
+		return next(req)
+	})
+	// 未携带 token 的流被拒绝
+	s.AddServiceInterceptor(9, func(req Request, next Invoker) (proto.Message, error) {
+		if req.GetMetadata()["token"] != "secret" {
+			return nil, NewError(context.Code_ERR_INVALID_SESSION, "unauthorized")
+		}
+		req.SetTrailer("auth", "ok")
+		return next(req)
+	})
+	s.RegisterStreamHandler(9, 1, func(s ServerStream) error {
+		return s.Send(&wrappers.StringValue{Value: "hello"})
+	})
+	s.Start()
+	defer s.Stop()
+
+	c := client.NewClient()
+	c.Dial(s.Addr().String())
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Second*3)
+	defer cancel()
+
+	denied, err := c.NewStream(ctx, 9, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = denied.Recv(new(wrappers.StringValue))
+	if code := status.CodeOf(err); code != status.Code(context.Code_ERR_INVALID_SESSION) {
+		t.Errorf("unauthorized stream error = %v, want %s", err, context.Code_ERR_INVALID_SESSION)
+	}
+
+	allowed, err := c.NewStream(ctx, 9, 1, client.Metadata("token", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(wrappers.StringValue)
+	if err := allowed.Recv(out); err != nil || out.Value != "hello" {
+		t.Fatalf("recv = %v, %v", out, err)
+	}
+	if err := allowed.Recv(out); err != io.EOF {
+		t.Errorf("recv after end = %v, want %v", err, io.EOF)
+	}
+	if v := allowed.Trailer()["auth"]; v != "ok" {
+		t.Errorf("trailer auth = %q, want %q", v, "ok")
+	}
+	if n := atomic.LoadInt32(&opened); n != 2 {
+		t.Errorf("global interceptor saw %d streams, want 2", n)
+	}
+}
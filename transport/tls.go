@@ -0,0 +1,138 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/treeforest/logger"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+	证书热加载模块
+	每次TLS握手时检查证书、私钥文件的修改时间，文件有变化则重新加载，
+	加载失败时继续使用旧的证书
+*/
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	lock        sync.RWMutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// 重新加载证书
+func (r *certReloader) reload() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	r.lock.Unlock()
+
+	log.Infof("load tls certificate %s success", r.certFile)
+	return nil
+}
+
+// 证书、私钥文件是否被修改
+func (r *certReloader) modified() bool {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return false
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return false
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return !certInfo.ModTime().Equal(r.certModTime) || !keyInfo.ModTime().Equal(r.keyModTime)
+}
+
+// 作为 tls.Config.GetCertificate 使用
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if r.modified() {
+		if err := r.reload(); err != nil {
+			log.Errorf("reload tls certificate %s error: %v", r.certFile, err)
+		}
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.cert, nil
+}
+
+// 根据配置项生成服务器的TLS配置，未配置TLS时返回nil
+func newServerTLSConfig(opts ServerOptions) (*tls.Config, error) {
+	if opts.TLSConfig == nil && opts.TLSCertFile == "" && opts.TLSClientCAFile == "" {
+		return nil, nil
+	}
+
+	var config *tls.Config
+	if opts.TLSConfig != nil {
+		config = opts.TLSConfig.Clone()
+	} else {
+		config = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if opts.TLSCertFile != "" {
+		reloader, err := newCertReloader(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls certificate error: %v", err)
+		}
+		config.Certificates = nil
+		config.GetCertificate = reloader.GetCertificate
+	}
+
+	if len(config.Certificates) == 0 && config.GetCertificate == nil {
+		return nil, errors.New("tls certificate is not configured")
+	}
+
+	// 双向认证：校验客户端证书
+	if opts.TLSClientCAFile != "" {
+		caData, err := ioutil.ReadFile(opts.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls client ca error: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no certificate found in %s", opts.TLSClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
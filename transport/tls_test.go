@@ -0,0 +1,261 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/treeforest/gos/client"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 测试用的证书
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// 生成证书，parent 为空时生成自签名的CA证书
+func newTestCert(t *testing.T, cn string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key error: %v", err)
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	parentCert, parentKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("create certificate error: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key error: %v", err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// 将证书、私钥写入文件
+func (c *testCert) writeFiles(t *testing.T, dir, name string) (certFile, keyFile string) {
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, c.certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, c.keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// 将请求原样返回的路由
+type echoRouter struct {
+	BaseRouter
+}
+
+func (r *echoRouter) Handle(req Request) {
+	req.GetConnection().Send(req.GetContext(), req.GetContext().GetData())
+}
+
+func startTLSServer(t *testing.T, opts ...ServerOption) Server {
+	s := NewServer(append([]ServerOption{WithAddress("127.0.0.1", 0)}, opts...)...)
+	s.RegisterRouter(1, &echoRouter{})
+	s.Start()
+	return s
+}
+
+// 在超时时间内等待客户端收到消息
+func recvTimeout(c client.Client, timeout time.Duration) client.Message {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if msg := c.Recv(); msg != nil {
+			return msg
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return nil
+}
+
+// 测试TLS链接的收发
+func TestTLSServer(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gos-tls")
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "gos test ca", nil)
+	certFile, keyFile := newTestCert(t, "gos server", ca).writeFiles(t, dir, "server")
+
+	s := startTLSServer(t, WithTLS(certFile, keyFile))
+	defer s.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	c := client.NewClient()
+	c.DialTLS(s.Addr().String(), &tls.Config{RootCAs: pool})
+	c.Send(1, 2, []byte("hello"))
+
+	msg := recvTimeout(c, time.Second*3)
+	if msg == nil {
+		t.Fatal("recv message timeout")
+	}
+	if string(msg.GetData()) != "hello" {
+		t.Errorf("recv data = %q, want %q", msg.GetData(), "hello")
+	}
+}
+
+// 测试双向认证
+func TestTLSClientAuth(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gos-tls")
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "gos test ca", nil)
+	certFile, keyFile := newTestCert(t, "gos server", ca).writeFiles(t, dir, "server")
+	caFile, _ := ca.writeFiles(t, dir, "ca")
+
+	s := startTLSServer(t, WithTLS(certFile, keyFile), WithTLSClientCA(caFile))
+	defer s.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	// 携带CA签发的客户端证书，可以正常通信
+	c := client.NewClient()
+	c.DialTLS(s.Addr().String(), &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{newTestCert(t, "gos client", ca).tlsCertificate(t)},
+	})
+	c.Send(1, 2, []byte("hello"))
+	if msg := recvTimeout(c, time.Second*3); msg == nil {
+		t.Fatal("recv message timeout")
+	}
+
+	// 未携带客户端证书，服务器拒绝链接
+	conn, err := tls.Dial("tcp", s.Addr().String(), &tls.Config{RootCAs: pool})
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Second * 3))
+	conn.Write([]byte{0})
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("connection without client certificate should be rejected")
+	}
+}
+
+// 测试证书热加载
+func TestTLSCertificateReload(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gos-tls")
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "gos test ca", nil)
+	certFile, keyFile := newTestCert(t, "server v1", ca).writeFiles(t, dir, "server")
+
+	s := startTLSServer(t, WithTLS(certFile, keyFile))
+	defer s.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	peerName := func() string {
+		conn, err := tls.Dial("tcp", s.Addr().String(), &tls.Config{RootCAs: pool})
+		if err != nil {
+			t.Fatalf("dial tls error: %v", err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+
+	if name := peerName(); name != "server v1" {
+		t.Fatalf("peer certificate = %s, want server v1", name)
+	}
+
+	// 替换证书文件，并修改文件时间确保能被检测到
+	newTestCert(t, "server v2", ca).writeFiles(t, dir, "server")
+	modTime := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, modTime, modTime)
+	os.Chtimes(keyFile, modTime, modTime)
+
+	if name := peerName(); name != "server v2" {
+		t.Fatalf("peer certificate = %s, want server v2", name)
+	}
+}
+
+// 测试TLS服务器拒绝链接时，不发送 ClientHello 的客户端不会一直占用回执的goroutine
+func TestTLSRejectSilentClient(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gos-tls")
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "gos test ca", nil)
+	certFile, keyFile := newTestCert(t, "gos server", ca).writeFiles(t, dir, "server")
+
+	s := startTLSServer(t, WithTLS(certFile, keyFile), WithMaxConn(1))
+	defer s.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	c := client.NewClient()
+	c.DialTLS(s.Addr().String(), &tls.Config{RootCAs: pool})
+	waitConnNum(t, s, 1)
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 握手超时后服务器关闭链接
+	conn.SetReadDeadline(time.Now().Add(rejectTimeout * 3))
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Errorf("rejected connection should be closed, read error: %v", err)
+	}
+	if n := s.GetRejectedCount(); n != 1 {
+		t.Errorf("rejected count = %d, want 1", n)
+	}
+}
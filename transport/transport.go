@@ -1,8 +1,12 @@
 package transport
 
 import (
+	gocontext "context"
+	"github.com/golang/protobuf/proto"
+	"github.com/treeforest/gos/transport/codec"
 	"github.com/treeforest/gos/transport/context"
 	"net"
+	"time"
 )
 
 /*
@@ -15,15 +19,65 @@ type Server interface {
 	// 停止服务器
 	Stop()
 
-	// 运行服务器
+	// 优雅关闭服务器：停止接受新链接，等待队列中的请求处理完毕，
+	// 将各链接待发送的消息写出后再关闭所有链接。ctx 超时则强制关闭剩余的链接并返回 ctx.Err()
+	Shutdown(ctx gocontext.Context) error
+
+	// 运行服务器(阻塞，直到收到 SIGINT/SIGTERM 信号或服务器被关闭)
 	Serve()
 
+	// 获取服务器TCP监听的地址，服务器未启动时返回nil
+	Addr() net.Addr
+
+	// 获取服务器所有监听器(TCP、WebSocket、KCP)的地址
+	Addrs() []net.Addr
+
 	// 给当前的服务注册路由
 	RegisterRouter(serviceID uint32, router Router)
 
+	// 给(serviceID, methodID)注册处理函数，形如
+	// func(req Request, in *XxxRequest) (*XxxResponse, error)
+	// 框架负责解析请求、序列化响应、设置返回码并回复客户端
+	RegisterHandler(serviceID, methodID uint32, handler interface{})
+
+	// 给(serviceID, methodID)注册流式处理函数，每个流在独立的goroutine中处理，不经过工作池，
+	// 但与普通请求一样经过全局拦截器和服务级拦截器。
+	// 处理函数返回时流结束，返回的错误(或拦截器返回的错误)作为流的返回码回复给客户端
+	RegisterStreamHandler(serviceID, methodID uint32, handler StreamHandler)
+
+	// 添加全局拦截器，作用于所有请求，按添加顺序执行
+	AddInterceptor(interceptors ...Interceptor)
+
+	// 添加服务级拦截器，仅作用于 serviceID 的请求，在全局拦截器之后执行
+	AddServiceInterceptor(serviceID uint32, interceptors ...Interceptor)
+
+	// 设置serviceID的请求优先级
+	SetServicePriority(serviceID uint32, priority context.Priority)
+
+	// 设置(serviceID, methodID)的请求优先级，优先于服务级的设置
+	SetMethodPriority(serviceID, methodID uint32, priority context.Priority)
+
+	// 获取工作池各优先级队列的统计信息
+	PriorityStats() []PriorityStats
+
 	// 获取当前的链接管理器
 	GetConnManager() ConnManager
 
+	// 获取分组管理器
+	GetGroupManager() GroupManager
+
+	// 向所有链接广播消息，返回消息成功放入发送队列的链接数
+	Broadcast(serviceID, methodID uint32, data []byte) int
+
+	// 获取当前的封包、拆包模块
+	GetDataPacker() DataPacker
+
+	// 获取服务器的配置项
+	GetOptions() ServerOptions
+
+	// 获取因超出最大连接数(或单个IP的最大连接数)而被拒绝的链接数
+	GetRejectedCount() uint64
+
 	// 设置在Server创建链接之前自动调用的函数
 	SetOnConnStartFunc(func(c Connection))
 
@@ -47,18 +101,35 @@ type Connection interface {
 	// 停止链接，结束当前链接的工作
 	Stop()
 
-	// 获取当前链接的绑定
-	GetTCPConnection() *net.TCPConn
+	// 获取当前链接的绑定(TCP、TLS、WebSocket 或 KCP 链接)
+	GetConn() net.Conn
 
 	// 获取当前链接模块的链接ID
 	GetConnID() uint32
 
-	// 获取远程客户端的 TCP状态
+	// 获取本地的地址
+	LocalAddr() net.Addr
+
+	// 获取远程客户端的地址
 	RemoteAddr() net.Addr
 
-	// 发送数据，将数据发送给远程的客户端
+	// 获取最后一次收到完整数据包的时间
+	GetLastActiveTime() time.Time
+
+	// 发送数据，将数据放入发送队列，由writer写给远程的客户端。
+	// 发送队列满时按照服务器配置的 SendPolicy 处理
 	Send(ctx *context.Context, data []byte) error
 
+	// 向客户端主动推送消息，使用独立的上下文，可在任意goroutine中调用。
+	// 客户端通过 OnPush 订阅 (serviceID, methodID) 的推送
+	Push(serviceID, methodID uint32, msg proto.Message) error
+
+	// 获取发送队列中等待写出的消息数
+	SendQueueLen() int
+
+	// 获取发送队列的容量
+	SendQueueCap() int
+
 	// 设置链接属性
 	SetProperty(key string, value interface{})
 
@@ -69,9 +140,6 @@ type Connection interface {
 	RemoveProperty(key string)
 }
 
-// 处理链接业务的方法
-type HandleFunc func(conn *net.TCPConn, data []byte, nLen int) error
-
 /*
  Request 接口
  实际上是把客户端请求的链接信息与数据包装到一个Request中
@@ -89,8 +157,21 @@ type Request interface {
 	// 获取方法ID
 	GetMethodID() uint32
 
-	// 获取session
-	GetSession() uint32
+	// 获取session(会话令牌)，未登录时为空
+	GetSession() string
+
+	// 获取请求序列号，回复时沿用请求的上下文即可原样带回
+	GetSeq() uint32
+
+	// 获取请求的 Go context，链接断开、超过客户端设置的超时时间或客户端取消请求时被取消，
+	// 可传递给 redis、mongo 等调用
+	Context() gocontext.Context
+
+	// 获取请求的元数据，如语言、客户端版本、鉴权token、链路id，不应修改
+	GetMetadata() map[string]string
+
+	// 设置响应的元数据(trailer)，随响应(包括错误响应)一起回复给客户端
+	SetTrailer(key, value string)
 }
 
 /*
@@ -108,62 +189,60 @@ type Router interface {
 }
 
 /*
- 将请求的消息封装到一个Message中，定义抽象的接口
+ 数据的封包、拆包 模块
+ 直接连接链接中的数据流，用于处理粘包问题，具体的包格式见 codec 包
 */
-type Message interface {
-	// 重置 context 到 message 中
-	Reset(ctx *context.Context)
+type DataPacker = codec.Packer
+
+/*
+ 消息管理抽象层
+*/
+type MessageHandler interface {
+	// 调度/执行对应的Router消息处理方法
+	HandleRequest(Request)
 
-	// 获取消息的长度
-	GetLen() uint32
+	// 为消息添加具体的处理逻辑
+	RegisterRouter(msgID uint32, router Router)
 
-	// 获取检验码
-	GetCheckSum() uint32
+	// 为(serviceID, methodID)注册处理函数
+	RegisterHandler(serviceID, methodID uint32, handler interface{})
 
-	//获取消息的内容
-	GetData() []byte
+	// 为(serviceID, methodID)注册流式处理函数
+	RegisterStreamHandler(serviceID, methodID uint32, handler StreamHandler)
 
-	//设置消息的长度
-	SetLen(uint32)
+	// 获取(serviceID, methodID)的流式处理函数，不存在时返回携带错误码的错误
+	GetStreamHandler(serviceID, methodID uint32) (StreamHandler, error)
 
-	// 设置校验码
-	SetCheckSum(uint32)
+	// 依次经过全局拦截器和服务级拦截器后执行流式处理函数 handle，返回流的结果
+	HandleStream(req Request, handle func() error) error
 
-	// 设置消息的内容
-	SetData([]byte)
+	// 添加全局拦截器
+	AddInterceptor(interceptors ...Interceptor)
 
-	// 检测校验和
-	ChecksumIEEE() bool
-}
+	// 添加服务级拦截器
+	AddServiceInterceptor(serviceID uint32, interceptors ...Interceptor)
 
-/*
- 数据的封包、拆包 模块
- 直接连接TCP连接中的数据流，用于处理TCP粘包问题
-*/
-type DataPacker interface {
-	// 获取数据包长度
-	GetHeadLen() uint32
+	// 设置处理请求发生 panic 时调用的函数
+	SetPanicHandler(f PanicHandler)
 
-	// 封包方法
-	Pack(Message) ([]byte, error)
+	// 设置serviceID的请求优先级
+	SetServicePriority(serviceID uint32, priority context.Priority)
 
-	// 拆包方法
-	Unpack([]byte, Message) error
-}
+	// 设置(serviceID, methodID)的请求优先级
+	SetMethodPriority(serviceID, methodID uint32, priority context.Priority)
 
-/*
- 消息管理抽象层
-*/
-type MessageHandler interface {
-	// 调度/执行对应的Router消息处理方法
-	HandleRequest(Request)
+	// 设置是否采用客户端携带的请求优先级，默认不采用
+	SetClientPriority(allow bool)
 
-	// 为消息添加具体的处理逻辑
-	RegisterRouter(msgID uint32, router Router)
+	// 获取各优先级队列的统计信息
+	PriorityStats() []PriorityStats
 
 	// 启动工作池
 	StartWorkerPool()
 
+	// 停止工作池：不再接收新的任务，等待已入队的任务处理完毕
+	StopWorkerPool(ctx gocontext.Context) error
+
 	// 将执行的任务交给工作池处理
 	EntryTaskToWorkerPool(req Request)
 }
@@ -184,6 +263,42 @@ type ConnManager interface {
 	// 当前连接总数
 	Len() uint32
 
+	// 来自 ip 的连接数
+	LenByIP(ip string) uint32
+
 	// 清除并终止所有连接
 	ClearAllConn()
+
+	// 并发终止所有连接，ctx 结束时强制关闭剩余的连接并返回 ctx.Err()
+	ClearAllConnContext(ctx gocontext.Context) error
+
+	// 遍历所有链接，f 返回false时停止遍历
+	Range(f func(conn Connection) bool)
+}
+
+/*
+ 分组管理模块的抽象接口
+*/
+type GroupManager interface {
+	// 将链接加入分组
+	Join(group string, conn Connection)
+
+	// 将链接移出分组
+	Leave(group string, conn Connection)
+
+	// 将链接移出其加入的所有分组，链接断开时自动调用
+	LeaveAll(conn Connection)
+
+	// 获取分组的所有成员
+	Members(group string) []Connection
+
+	// 获取分组的成员数
+	Len(group string) int
+
+	// 获取链接加入的所有分组
+	Groups(conn Connection) []string
+
+	// 向分组的所有成员广播消息，消息只编码一次，各链接按自身的发送策略入队。
+	// 返回消息成功放入发送队列的链接数
+	Broadcast(group string, serviceID, methodID uint32, data []byte) int
 }
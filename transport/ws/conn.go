@@ -0,0 +1,104 @@
+// Package ws 将 WebSocket 链接适配为 net.Conn，使其与TCP链接使用相同的数据包格式。
+// 每次 Write 作为一个二进制消息发送，Read 则将收到的二进制消息视为连续的字节流。
+package ws
+
+import (
+	"github.com/gorilla/websocket"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type conn struct {
+	ws *websocket.Conn
+
+	// 当前正在读取的消息
+	reader io.Reader
+
+	// websocket 不支持并发写数据消息(控制消息除外)
+	writeLock sync.Mutex
+}
+
+// 将 WebSocket 链接包装为 net.Conn
+func NewConn(ws *websocket.Conn) net.Conn {
+	return &conn{ws: ws}
+}
+
+// 连接 WebSocket 服务器，url 形如 ws://127.0.0.1:9998/ws
+func Dial(url string, header http.Header) (net.Conn, error) {
+	ws, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(ws), nil
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			messageType, r, err := c.ws.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			if messageType != websocket.BinaryMessage {
+				// 忽略非二进制消息
+				continue
+			}
+			c.reader = r
+		}
+
+		n, err := c.reader.Read(b)
+		if err == io.EOF {
+			// 当前消息读取完毕，继续读取下一条消息
+			c.reader = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if err := c.ws.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// 关闭链接。WriteControl 可以与 Write 并发调用，不持有 writeLock，避免被阻塞在慢速客户端上的 Write 卡住
+func (c *conn) Close() error {
+	c.ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+
+	return c.ws.Close()
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	return c.ws.LocalAddr()
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	return c.ws.SetWriteDeadline(t)
+}
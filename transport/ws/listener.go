@@ -0,0 +1,105 @@
+package ws
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/gorilla/websocket"
+	"net"
+	"net/http"
+	"sync"
+)
+
+var errListenerClosed = errors.New("websocket listener closed")
+
+// WebSocket 监听器的地址
+type addr struct {
+	network string
+	address string
+}
+
+func (a *addr) Network() string { return a.network }
+func (a *addr) String() string  { return a.address }
+
+/*
+	WebSocket 监听器
+	在 path 上完成 HTTP 升级后，将链接以 net.Conn 的形式通过 Accept 返回
+*/
+type listener struct {
+	tcpListener net.Listener
+	httpServer  *http.Server
+	upgrader    websocket.Upgrader
+	addr        net.Addr
+
+	// 升级完成的链接
+	acceptChan chan net.Conn
+
+	closeOnce sync.Once
+	closeChan chan struct{}
+}
+
+// 在 address 上监听 WebSocket 链接。tlsConfig 不为空时使用 wss，
+// checkOrigin 为空时仅允许同源请求
+func Listen(network, address, path string, tlsConfig *tls.Config, checkOrigin func(r *http.Request) bool) (net.Listener, error) {
+	tcpListener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &listener{
+		tcpListener: tcpListener,
+		upgrader:    websocket.Upgrader{CheckOrigin: checkOrigin},
+		acceptChan:  make(chan net.Conn),
+		closeChan:   make(chan struct{}),
+	}
+
+	scheme := "ws"
+	if tlsConfig != nil {
+		scheme = "wss"
+		tcpListener = tls.NewListener(tcpListener, tlsConfig)
+	}
+	l.addr = &addr{network: scheme, address: l.tcpListener.Addr().String()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, l.handleUpgrade)
+	l.httpServer = &http.Server{Handler: mux}
+
+	go l.httpServer.Serve(tcpListener)
+
+	return l, nil
+}
+
+func (l *listener) handleUpgrade(w http.ResponseWriter, r *http.Request) {
+	ws, err := l.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade 已经向客户端回复了错误
+		return
+	}
+
+	select {
+	case l.acceptChan <- NewConn(ws):
+	case <-l.closeChan:
+		ws.Close()
+	}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.acceptChan:
+		return conn, nil
+	case <-l.closeChan:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closeChan)
+		err = l.httpServer.Close()
+	})
+	return err
+}
+
+func (l *listener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/treeforest/gos/client"
+	"github.com/treeforest/gos/transport/ws"
+	"testing"
+	"time"
+)
+
+// 测试TCP与WebSocket链接共享同一个Server
+func TestWebSocketServer(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1", 0), WithWebSocket("127.0.0.1", 0, "/ws"))
+	s.RegisterRouter(1, &echoRouter{})
+	s.Start()
+	defer s.Stop()
+
+	addrs := s.Addrs()
+	if len(addrs) != 2 || addrs[1].Network() != "ws" {
+		t.Fatalf("server addrs = %v, want tcp and ws listeners", addrs)
+	}
+
+	tcpClient := client.NewClient()
+	tcpClient.Dial(addrs[0].String())
+
+	wsClient := client.NewClient()
+	wsClient.DialWebSocket(fmt.Sprintf("ws://%s/ws", addrs[1]), nil)
+
+	for i, c := range []client.Client{tcpClient, wsClient} {
+		data := fmt.Sprintf("hello %d", i)
+		c.Send(1, 2, []byte(data))
+
+		msg := recvTimeout(c, time.Second*3)
+		if msg == nil {
+			t.Fatalf("client %d recv message timeout", i)
+		}
+		if string(msg.GetData()) != data {
+			t.Errorf("client %d recv data = %q, want %q", i, msg.GetData(), data)
+		}
+	}
+
+	if n := s.GetConnManager().Len(); n != 2 {
+		t.Errorf("connection count = %d, want 2", n)
+	}
+}
+
+// 测试写入阻塞在不读取数据的客户端上时，Close 依然能及时返回并中断写入
+func TestWebSocketCloseDuringWrite(t *testing.T) {
+	l, err := ws.Listen("tcp", "127.0.0.1:0", "/ws", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	peer, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s/ws", l.Addr()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		data := make([]byte, 1024*1024)
+		for {
+			if _, err := conn.Write(data); err != nil {
+				return
+			}
+		}
+	}()
+	time.Sleep(time.Millisecond * 300)
+
+	closed := make(chan struct{})
+	go func() {
+		conn.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second * 3):
+		t.Fatal("close is blocked by the pending write")
+	}
+	select {
+	case <-written:
+	case <-time.After(time.Second * 3):
+		t.Fatal("pending write is not interrupted by close")
+	}
+}